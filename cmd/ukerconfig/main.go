@@ -0,0 +1,156 @@
+// Command ukerconfig generates a .env.example file and a markdown reference from a config
+// struct using the same tags as config.Loader.
+//
+// The struct is loaded by compiling a short-lived program inside the current module, so the
+// command must run from a module that can import both the target package and uker:
+//
+//	go run github.com/unknowns24/uker/cmd/ukerconfig \
+//		-pkg example.com/service/internal/settings -type AppConfig -prefix APP \
+//		-env .env.example -md docs/config.md
+//
+// With -check the files are not written; the command exits with status 1 when they differ
+// from the generated content, which is useful in CI.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"text/template"
+)
+
+var generatorTemplate = template.Must(template.New("main").Parse(`package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/unknowns24/uker/uker/config"
+	target "{{ .Package }}"
+)
+
+func main() {
+	loader := config.New({{ .Prefix }})
+
+	var env, md bytes.Buffer
+	if err := loader.WriteEnvExample(&env, &target.{{ .Type }}{}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := loader.WriteMarkdown(&md, &target.{{ .Type }}{}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile({{ .EnvOut }}, env.Bytes(), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile({{ .MarkdownOut }}, md.Bytes(), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`))
+
+func main() {
+	pkg := flag.String("pkg", "", "import path of the package declaring the config struct")
+	typeName := flag.String("type", "", "name of the config struct")
+	prefix := flag.String("prefix", "", "prefix passed to config.New")
+	envPath := flag.String("env", ".env.example", "output path of the .env example (empty to skip)")
+	mdPath := flag.String("md", "", "output path of the markdown reference (empty to skip)")
+	check := flag.Bool("check", false, "compare the outputs with the existing files instead of writing them")
+	flag.Parse()
+
+	if *pkg == "" || *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	env, md, err := generate(*pkg, *typeName, *prefix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ukerconfig: %v\n", err)
+		os.Exit(1)
+	}
+
+	outputs := []struct {
+		path    string
+		content []byte
+	}{{*envPath, env}, {*mdPath, md}}
+
+	failed := false
+	for _, output := range outputs {
+		if output.path == "" {
+			continue
+		}
+
+		if *check {
+			existing, err := os.ReadFile(output.path)
+			if err != nil || !bytes.Equal(existing, output.content) {
+				fmt.Fprintf(os.Stderr, "ukerconfig: %s is out of date\n", output.path)
+				failed = true
+			}
+			continue
+		}
+
+		if err := os.WriteFile(output.path, output.content, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "ukerconfig: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// generate builds and runs a temporary program importing the target package, returning the
+// .env example and markdown contents.
+func generate(pkg, typeName, prefix string) ([]byte, []byte, error) {
+	dir, err := os.MkdirTemp(".", ".ukerconfig-")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	envOut := filepath.Join(dir, "env.out")
+	mdOut := filepath.Join(dir, "md.out")
+
+	var source bytes.Buffer
+	err = generatorTemplate.Execute(&source, map[string]string{
+		"Package":     pkg,
+		"Type":        typeName,
+		"Prefix":      strconv.Quote(prefix),
+		"EnvOut":      strconv.Quote(envOut),
+		"MarkdownOut": strconv.Quote(mdOut),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), source.Bytes(), 0o644); err != nil {
+		return nil, nil, err
+	}
+
+	cmd := exec.Command("go", "run", "./"+filepath.Base(dir))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, nil, fmt.Errorf("running generator: %w", err)
+	}
+
+	env, err := os.ReadFile(envOut)
+	if err != nil {
+		return nil, nil, err
+	}
+	md, err := os.ReadFile(mdOut)
+	if err != nil {
+		return nil, nil, err
+	}
+	return env, md, nil
+}
@@ -0,0 +1,139 @@
+// Command ukermigrate applies the versioned SQL migrations of a directory using the
+// connection settings that the service reads from the environment:
+//
+//	go run github.com/unknowns24/uker/cmd/ukermigrate \
+//		-driver postgres -prefix APP_DB -dir db/migrations up
+//
+// The supported commands are up, down [n] (default 1) and status. With -dry-run the
+// statements are printed instead of executed, which is useful to review a deploy in CI.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/unknowns24/uker/uker/config"
+	"github.com/unknowns24/uker/uker/db"
+	"github.com/unknowns24/uker/uker/db/migrate"
+	"github.com/unknowns24/uker/uker/db/postgres"
+	"github.com/unknowns24/uker/uker/db/sqlite"
+)
+
+func main() {
+	driver := flag.String("driver", string(db.DriverMySQL), "database driver: mysql, postgres or sqlite")
+	prefix := flag.String("prefix", "DB", "environment prefix of the connection settings")
+	dir := flag.String("dir", "migrations", "directory containing the NNNN_name.up.sql/down.sql files")
+	table := flag.String("table", migrate.DefaultTable, "table recording the applied versions")
+	dryRun := flag.Bool("dry-run", false, "print the statements instead of executing them")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ukermigrate [flags] up|down [n]|status\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, *driver, *prefix, *dir, *table, *dryRun, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "ukermigrate: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, driver, prefix, dir, table string, dryRun bool, args []string) error {
+	dialect, err := loadDialect(driver, prefix)
+	if err != nil {
+		return err
+	}
+
+	gdb, err := db.New(dialect).OpenContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	opts := []migrate.Option{migrate.WithFS(os.DirFS(dir), "."), migrate.WithTable(table)}
+	if dryRun {
+		opts = append(opts, migrate.WithDryRun(os.Stdout))
+	}
+	migrator, err := migrate.New(gdb, opts...)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		report("applied", applied)
+		return err
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, n)
+		report("reverted", reverted)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func loadDialect(driver, prefix string) (db.Dialect, error) {
+	loader := config.New(prefix)
+
+	switch db.Driver(driver) {
+	case db.DriverMySQL:
+		var data db.MySQLConnData
+		if err := loader.Load(&data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	case db.DriverPostgres:
+		var data postgres.ConnData
+		if err := loader.Load(&data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	case db.DriverSQLite:
+		var data sqlite.ConnData
+		if err := loader.Load(&data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unknown driver %q", driver)
+	}
+}
+
+func report(verb string, migrations []migrate.Migration) {
+	for _, migration := range migrations {
+		fmt.Printf("%s %d_%s\n", verb, migration.Version, migration.Name)
+	}
+}
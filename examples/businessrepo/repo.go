@@ -1,10 +1,12 @@
 package businessrepo
 
 import (
+	"context"
 	"errors"
 	"net/url"
 	"time"
 
+	"github.com/unknowns24/uker/uker/db"
 	"github.com/unknowns24/uker/uker/pagination"
 	"gorm.io/gorm"
 )
@@ -12,9 +14,8 @@ import (
 var errNilDB = errors.New("businessrepo: nil db")
 
 type BusinessRepo struct {
-	db           *gorm.DB
-	cursorSecret []byte
-	cursorTTL    time.Duration
+	db      *gorm.DB
+	members *db.Repository[BusinessMember]
 }
 
 type BusinessMember struct {
@@ -27,17 +28,19 @@ func (BusinessMember) TableName() string {
 	return "business_members"
 }
 
-func NewBusinessRepo(db *gorm.DB, secret []byte, ttl time.Duration) (*BusinessRepo, error) {
-	if db == nil {
+func NewBusinessRepo(conn *gorm.DB, secret []byte, ttl time.Duration) (*BusinessRepo, error) {
+	if conn == nil {
 		return nil, errNilDB
 	}
 	if len(secret) == 0 {
 		return nil, errors.New("businessrepo: missing cursor secret")
 	}
-	if ttl < 0 {
-		ttl = 0
+
+	members, err := db.NewRepository[BusinessMember](conn, secret, ttl)
+	if err != nil {
+		return nil, err
 	}
-	return &BusinessRepo{db: db, cursorSecret: append([]byte(nil), secret...), cursorTTL: ttl}, nil
+	return &BusinessRepo{db: conn, members: members}, nil
 }
 
 func (r *BusinessRepo) ListMembers(businessID string, raw url.Values) (*pagination.PagingResponse[BusinessMember], error) {
@@ -45,45 +48,15 @@ func (r *BusinessRepo) ListMembers(businessID string, raw url.Values) (*paginati
 		return nil, errNilDB
 	}
 
-	params, err := pagination.ParseWithSecurity(raw, r.cursorSecret, r.cursorTTL)
-	if err != nil {
-		return nil, err
-	}
-
-	base := r.db.Model(&BusinessMember{}).Where("business_id = ?", businessID)
-	countParams := params
-	countParams.Cursor = nil
-	countParams.RawCursor = ""
-	countParams.Limit = 0
-
-	countQuery, err := pagination.Apply(base, countParams)
-	if err != nil {
-		return nil, err
-	}
-
-	var total int64
-	if err := countQuery.Count(&total).Error; err != nil {
-		return nil, err
-	}
-	query, err := pagination.Apply(base, params)
-	if err != nil {
-		return nil, err
-	}
-
-	var results []BusinessMember
-	if err := query.Find(&results).Error; err != nil {
-		return nil, err
-	}
-
-	limit := params.Limit
-	if limit <= 0 {
-		limit = pagination.DefaultLimit
-	}
+	return r.members.List(context.Background(), raw, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("business_id = ?", businessID)
+	})
+}
 
-	page, err := pagination.BuildPageSigned[BusinessMember](params, results, limit, total, nil, r.cursorSecret)
-	if err != nil {
-		return nil, err
+// AddMember joins the transaction carried by ctx when called inside db.WithTx.
+func (r *BusinessRepo) AddMember(ctx context.Context, member *BusinessMember) error {
+	if r == nil || r.db == nil {
+		return errNilDB
 	}
-
-	return &page, nil
+	return r.members.Create(ctx, member)
 }
@@ -1,11 +1,16 @@
 package businessrepo
 
 import (
+	"context"
+	"errors"
 	"net/url"
 	"testing"
 	"time"
 
+	"github.com/unknowns24/uker/uker/db"
+	"github.com/unknowns24/uker/uker/db/dbtest"
 	"github.com/unknowns24/uker/uker/pagination"
+	"gorm.io/gorm"
 )
 
 var testSecret = []byte("test-secret")
@@ -155,3 +160,25 @@ func TestBuildPage_RequiresExtractorWhenNeeded(t *testing.T) {
 		t.Fatalf("expected error when automatic extraction cannot find field")
 	}
 }
+
+func TestAddMember_JoinsOuterTransaction(t *testing.T) {
+	conn := dbtest.Open(t, dbtest.WithModels(&BusinessMember{}))
+
+	repo, err := NewBusinessRepo(conn, testSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("NewBusinessRepo returned error: %v", err)
+	}
+
+	rollback := errors.New("rollback")
+	err = db.WithTx(context.Background(), conn, func(tx *gorm.DB) error {
+		if err := repo.AddMember(tx.Statement.Context, &BusinessMember{ID: "mem-1", BusinessID: "biz-1", CreatedAt: time.Now().UTC()}); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("expected rollback error, got %v", err)
+	}
+
+	dbtest.AssertRowCount(t, conn, "business_members", 0)
+}
@@ -5,20 +5,30 @@ go 1.24
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
 	github.com/bmizerany/assert v0.0.0-20160611221934-b7ed37b82869 // indirect
-	github.com/go-sql-driver/mysql v1.9.2 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
+	github.com/jackc/pgx/v5 v5.5.5 // indirect
+	github.com/jackc/puddle/v2 v2.2.1 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
 	github.com/kr/pretty v0.3.1 // indirect
+	github.com/mattn/go-sqlite3 v1.14.22 // indirect
 	github.com/philhofer/fwd v1.1.3-0.20240916144458-20a13a1f6b7c // indirect
 	github.com/tinylib/msgp v1.3.0 // indirect
+	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/sync v0.14.0 // indirect
 	golang.org/x/text v0.25.0 // indirect
 )
 
 require (
-        github.com/fluent/fluent-logger-golang v1.9.0
-        github.com/sirupsen/logrus v1.9.3
-        golang.org/x/exp v0.0.0-20250506013437-ce4c2cf36ca6
-        golang.org/x/sys v0.33.0 // indirect
-        gorm.io/driver/mysql v1.5.7
-        gorm.io/gorm v1.26.1
+	github.com/fluent/fluent-logger-golang v1.9.0
+	github.com/go-sql-driver/mysql v1.9.2
+	github.com/sirupsen/logrus v1.9.3
+	golang.org/x/exp v0.0.0-20250506013437-ce4c2cf36ca6
+	golang.org/x/sys v0.33.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1
+	gorm.io/driver/mysql v1.5.7
+	gorm.io/driver/postgres v1.5.11
+	gorm.io/driver/sqlite v1.5.7
+	gorm.io/gorm v1.26.1
 )
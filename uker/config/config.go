@@ -1,23 +1,51 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"reflect"
-	"strings"
+
+	"github.com/unknowns24/uker/uker/validate"
 )
 
-// Loader loads configuration structs from environment variables.
+// Loader loads configuration structs from environment variables and other sources.
 type Loader struct {
-	prefix string
+	prefix  string
+	sources []Source
 }
 
-// New creates a new Loader with the provided prefix.
-func New(prefix string) Loader {
-	return Loader{prefix: prefix}
+// New creates a new Loader with the provided prefix. Without options it reads the process
+// environment; use WithSources to stack .env files, JSON files or maps.
+func New(prefix string, opts ...Option) Loader {
+	loader := Loader{prefix: prefix, sources: []Source{Env()}}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&loader)
+		}
+	}
+	return loader
 }
 
-// Load reads the environment variables into the provided struct pointer using the `config` tag.
+// Load reads the configured sources into the provided struct pointer using the `config` tag.
+// Values are decoded according to the field type; a malformed value returns a *DecodeError
+// naming the environment variable and the target type.
+//
+// Nested structs and pointers to structs are walked recursively and their names compose the
+// key, so a `DB` field holding a `Host` field is read from PREFIX_DB_HOST. Embedded structs
+// use their type name as a segment unless tagged with `config:",squash"`.
+//
+// Fields without a variable take the value of their `default:"..."` tag, and fields tagged
+// `config:"name,required"` must resolve to a non-empty value. Every missing or malformed
+// variable is collected into a single *LoadError. A pointer to struct stays nil unless one
+// of its keys is set by a source; defaults alone do not allocate it and its required keys
+// are only enforced once it is allocated.
+//
+// Loaded values are checked against the rules declared in the `uker` tag (see
+// validate.Rules), e.g. `uker:"port"` or `uker:"min=1s,max=1m"`; violations are reported as
+// *ValidationError.
+//
+// For any key, a PREFIX_KEY_FILE variable may point to a file holding the value (as done by
+// Docker and Kubernetes secrets). The trailing newline is trimmed.
 func (l Loader) Load(target any) error {
 	value := reflect.ValueOf(target)
 	if value.Kind() != reflect.Ptr || value.IsNil() {
@@ -29,18 +57,107 @@ func (l Loader) Load(target any) error {
 		return fmt.Errorf("target must point to a struct")
 	}
 
-	for i := 0; i < elem.NumField(); i++ {
-		field := elem.Type().Field(i)
-		key := field.Tag.Get("config")
-		if key == "" {
-			key = field.Name
+	bindings, err := l.bind(elem.Type())
+	if err != nil {
+		return err
+	}
+
+	return apply(elem, bindings)
+}
+
+// apply decodes the bindings into elem, collecting every problem into a *LoadError.
+//
+// Optional sections, pointers to structs, are only allocated by values coming from a source:
+// defaults alone leave them nil so nil still means the section was not configured, and the
+// required keys of a section left nil are not reported as missing.
+func apply(elem reflect.Value, bindings []binding) error {
+	for _, binding := range bindings {
+		if binding.found && binding.source != SourceDefault {
+			fieldByIndex(elem, binding.spec.index)
+		}
+	}
+
+	var problems []error
+	for _, binding := range bindings {
+		if binding.err != nil {
+			var missing *MissingError
+			if _, ok := readField(elem, binding.spec.index); ok || !errors.As(binding.err, &missing) {
+				problems = append(problems, binding.err)
+			}
+			continue
+		}
+		if !binding.found {
+			continue
+		}
+
+		field, ok := readField(elem, binding.spec.index)
+		if !ok {
+			continue
+		}
+		if err := decodeValue(field, binding.raw); err != nil {
+			problems = append(problems, &DecodeError{Key: binding.spec.key, Type: binding.spec.field.Type, Err: err})
+			continue
 		}
 
-		envKey := strings.ToUpper(fmt.Sprintf("%s_%s", l.prefix, key))
-		if value, ok := os.LookupEnv(envKey); ok {
-			elem.Field(i).SetString(value)
+		if rules := binding.spec.field.Tag.Get(validate.TagName); rules != "" {
+			if err := validate.Rules(field.Interface(), rules); err != nil {
+				problems = append(problems, &ValidationError{Key: binding.spec.key, Err: err})
+			}
 		}
 	}
 
+	if len(problems) > 0 {
+		return &LoadError{Errors: problems}
+	}
+
 	return nil
 }
+
+// binding is the raw value selected for a field after applying source precedence, the _FILE
+// convention and defaults.
+type binding struct {
+	spec   fieldSpec
+	raw    string
+	source string
+	found  bool
+	err    error
+}
+
+func (l Loader) bind(typ reflect.Type) ([]binding, error) {
+	values, err := l.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	specs := collectFields(typ, l.prefix)
+	bindings := make([]binding, 0, len(specs))
+	for _, spec := range specs {
+		current := binding{spec: spec}
+
+		resolved, ok := values[spec.key]
+		if fileRef, hasFile := values[spec.key+fileSuffix]; hasFile && (!ok || fileRef.rank >= resolved.rank) {
+			content, err := readSecretFile(fileRef.value)
+			if err != nil {
+				current.err = &DecodeError{Key: spec.key + fileSuffix, Type: spec.field.Type, Err: err}
+				bindings = append(bindings, current)
+				continue
+			}
+			resolved, ok = resolvedValue{value: content, source: "file:" + fileRef.value}, true
+		}
+
+		switch {
+		case ok:
+			current.raw, current.source, current.found = resolved.value, resolved.source, true
+		case spec.hasDefault:
+			current.raw, current.source, current.found = spec.defaultValue, SourceDefault, true
+		}
+
+		if spec.tag.has(optionRequired) && current.raw == "" {
+			current.err = &MissingError{Key: spec.key}
+		}
+
+		bindings = append(bindings, current)
+	}
+
+	return bindings, nil
+}
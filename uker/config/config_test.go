@@ -1,8 +1,13 @@
 package config
 
 import (
+	"errors"
+	"net/url"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 type testConfig struct {
@@ -22,3 +27,293 @@ func TestLoader(t *testing.T) {
 		t.Fatalf("DSN = %s", cfg.DSN)
 	}
 }
+
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "debug":
+		*l = 1
+	case "info":
+		*l = 2
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
+type typedConfig struct {
+	Port     int               `config:"port"`
+	Workers  uint8             `config:"workers"`
+	Ratio    float64           `config:"ratio"`
+	Debug    bool              `config:"debug"`
+	Timeout  time.Duration     `config:"timeout"`
+	Started  time.Time         `config:"started"`
+	Endpoint url.URL           `config:"endpoint"`
+	Hosts    []string          `config:"hosts"`
+	Ports    []int             `config:"ports"`
+	Labels   map[string]string `config:"labels"`
+	Level    level             `config:"level"`
+	Limit    *int              `config:"limit"`
+}
+
+func TestLoaderTypedFields(t *testing.T) {
+	t.Setenv("APP_PORT", "8080")
+	t.Setenv("APP_WORKERS", "4")
+	t.Setenv("APP_RATIO", "0.5")
+	t.Setenv("APP_DEBUG", "true")
+	t.Setenv("APP_TIMEOUT", "1m30s")
+	t.Setenv("APP_STARTED", "2024-01-02T03:04:05Z")
+	t.Setenv("APP_ENDPOINT", "https://example.com/api")
+	t.Setenv("APP_HOSTS", "a, b,c")
+	t.Setenv("APP_PORTS", "80,443")
+	t.Setenv("APP_LABELS", "env=prod, team=core")
+	t.Setenv("APP_LEVEL", "info")
+	t.Setenv("APP_LIMIT", "10")
+
+	var cfg typedConfig
+	if err := New("app").Load(&cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Port != 8080 || cfg.Workers != 4 || cfg.Ratio != 0.5 || !cfg.Debug {
+		t.Fatalf("unexpected scalars: %+v", cfg)
+	}
+	if cfg.Timeout != 90*time.Second {
+		t.Fatalf("Timeout = %s", cfg.Timeout)
+	}
+	if !cfg.Started.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("Started = %s", cfg.Started)
+	}
+	if cfg.Endpoint.Host != "example.com" || cfg.Endpoint.Path != "/api" {
+		t.Fatalf("Endpoint = %s", cfg.Endpoint.String())
+	}
+	if !reflect.DeepEqual(cfg.Hosts, []string{"a", "b", "c"}) {
+		t.Fatalf("Hosts = %v", cfg.Hosts)
+	}
+	if !reflect.DeepEqual(cfg.Ports, []int{80, 443}) {
+		t.Fatalf("Ports = %v", cfg.Ports)
+	}
+	if !reflect.DeepEqual(cfg.Labels, map[string]string{"env": "prod", "team": "core"}) {
+		t.Fatalf("Labels = %v", cfg.Labels)
+	}
+	if cfg.Level != 2 {
+		t.Fatalf("Level = %d", cfg.Level)
+	}
+	if cfg.Limit == nil || *cfg.Limit != 10 {
+		t.Fatalf("Limit = %v", cfg.Limit)
+	}
+}
+
+func TestLoaderDecodeErrorNamesKeyAndType(t *testing.T) {
+	t.Setenv("APP_PORT", "abc")
+
+	var cfg typedConfig
+	err := New("app").Load(&cfg)
+
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected DecodeError, got %v", err)
+	}
+	if decodeErr.Key != "APP_PORT" {
+		t.Fatalf("Key = %s", decodeErr.Key)
+	}
+	if !strings.Contains(err.Error(), "int") {
+		t.Fatalf("expected type in error, got %q", err.Error())
+	}
+}
+
+type serverConfig struct {
+	Host string `config:"host"`
+	Port int    `config:"port"`
+}
+
+type Common struct {
+	Name string `config:"name"`
+}
+
+type Shared struct {
+	Region string `config:"region"`
+}
+
+type nestedConfig struct {
+	Common `config:",squash"`
+	Shared
+	HTTP    serverConfig  `config:"http"`
+	Metrics *serverConfig `config:"metrics"`
+	Admin   *serverConfig `config:"admin"`
+	Ignored string        `config:"-"`
+}
+
+func TestLoaderNestedStructs(t *testing.T) {
+	t.Setenv("APP_NAME", "billing")
+	t.Setenv("APP_SHARED_REGION", "eu")
+	t.Setenv("APP_HTTP_HOST", "0.0.0.0")
+	t.Setenv("APP_HTTP_PORT", "8080")
+	t.Setenv("APP_METRICS_PORT", "9090")
+	t.Setenv("APP_IGNORED", "value")
+
+	var cfg nestedConfig
+	if err := New("app").Load(&cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Name != "billing" {
+		t.Fatalf("Name = %q", cfg.Name)
+	}
+	if cfg.Region != "eu" {
+		t.Fatalf("Region = %q", cfg.Region)
+	}
+	if cfg.HTTP.Host != "0.0.0.0" || cfg.HTTP.Port != 8080 {
+		t.Fatalf("HTTP = %+v", cfg.HTTP)
+	}
+	if cfg.Metrics == nil || cfg.Metrics.Port != 9090 {
+		t.Fatalf("Metrics = %+v", cfg.Metrics)
+	}
+	if cfg.Admin != nil {
+		t.Fatalf("expected Admin to stay nil, got %+v", cfg.Admin)
+	}
+	if cfg.Ignored != "" {
+		t.Fatalf("Ignored = %q", cfg.Ignored)
+	}
+}
+
+type defaultsConfig struct {
+	Host    string        `config:"host,required"`
+	Port    int           `config:"port" default:"3306"`
+	Timeout time.Duration `config:"timeout" default:"5s"`
+	User    string        `config:"user,required" default:"root"`
+	Name    string        `config:"name,required"`
+	Retries int           `config:"retries"`
+}
+
+func TestLoaderDefaults(t *testing.T) {
+	t.Setenv("APP_HOST", "db")
+	t.Setenv("APP_NAME", "app")
+	t.Setenv("APP_TIMEOUT", "10s")
+
+	var cfg defaultsConfig
+	if err := New("app").Load(&cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Port != 3306 {
+		t.Fatalf("Port = %d", cfg.Port)
+	}
+	if cfg.Timeout != 10*time.Second {
+		t.Fatalf("Timeout = %s", cfg.Timeout)
+	}
+	if cfg.User != "root" {
+		t.Fatalf("User = %q", cfg.User)
+	}
+}
+
+func TestLoaderAggregatesErrors(t *testing.T) {
+	t.Setenv("APP_NAME", "")
+	t.Setenv("APP_RETRIES", "many")
+
+	var cfg defaultsConfig
+	err := New("app").Load(&cfg)
+
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("expected LoadError, got %v", err)
+	}
+	if len(loadErr.Errors) != 3 {
+		t.Fatalf("expected 3 problems, got %d: %v", len(loadErr.Errors), err)
+	}
+
+	for _, key := range []string{"APP_HOST", "APP_NAME", "APP_RETRIES"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Fatalf("expected %s in error, got %q", key, err.Error())
+		}
+	}
+
+	var missing *MissingError
+	if !errors.As(err, &missing) || missing.Key != "APP_HOST" {
+		t.Fatalf("expected MissingError for APP_HOST, got %v", missing)
+	}
+}
+
+type validatedConfig struct {
+	Port     int           `config:"port" uker:"port"`
+	Timeout  time.Duration `config:"timeout" uker:"min=0s"`
+	Level    string        `config:"level" default:"info" uker:"oneof=debug info warn"`
+	Addr     string        `config:"addr" uker:"hostport"`
+	Optional int           `config:"optional" uker:"min=1"`
+}
+
+func TestLoaderValidatesRules(t *testing.T) {
+	t.Setenv("APP_PORT", "70000")
+	t.Setenv("APP_TIMEOUT", "-1s")
+	t.Setenv("APP_ADDR", "localhost:6379")
+
+	var cfg validatedConfig
+	err := New("app").Load(&cfg)
+
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("expected LoadError, got %v", err)
+	}
+	if len(loadErr.Errors) != 2 {
+		t.Fatalf("expected 2 problems, got %v", err)
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Key != "APP_PORT" {
+		t.Fatalf("expected ValidationError for APP_PORT, got %v", validationErr)
+	}
+	if !strings.Contains(err.Error(), "APP_TIMEOUT") {
+		t.Fatalf("expected APP_TIMEOUT in error, got %q", err.Error())
+	}
+}
+
+type optionalSectionConfig struct {
+	Cache *struct {
+		Host string        `config:"host"`
+		TTL  time.Duration `config:"ttl" default:"1m"`
+	} `config:"cache"`
+	Queue *struct {
+		Host string `config:"host"`
+		Size int    `config:"size" default:"100"`
+	} `config:"queue"`
+}
+
+func TestLoaderDefaultsDoNotAllocateOptionalSections(t *testing.T) {
+	t.Setenv("APP_QUEUE_HOST", "mq")
+
+	var cfg optionalSectionConfig
+	if err := New("app").Load(&cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Cache != nil {
+		t.Fatalf("expected the unconfigured Cache section to stay nil, got %+v", cfg.Cache)
+	}
+	if cfg.Queue == nil || cfg.Queue.Host != "mq" || cfg.Queue.Size != 100 {
+		t.Fatalf("expected defaults inside the configured Queue section, got %+v", cfg.Queue)
+	}
+}
+
+type optionalRequiredConfig struct {
+	Replica *struct {
+		Host string `config:"host,required"`
+		Port int    `config:"port" default:"3306"`
+	} `config:"replica"`
+}
+
+func TestLoaderRequiredKeysOfOptionalSections(t *testing.T) {
+	var cfg optionalRequiredConfig
+	if err := New("app").Load(&cfg); err != nil {
+		t.Fatalf("an unconfigured optional section should not need its required keys: %v", err)
+	}
+	if cfg.Replica != nil {
+		t.Fatalf("expected Replica to stay nil, got %+v", cfg.Replica)
+	}
+
+	t.Setenv("APP_REPLICA_PORT", "3307")
+	var missing *MissingError
+	if err := New("app").Load(&cfg); !errors.As(err, &missing) || missing.Key != "APP_REPLICA_HOST" {
+		t.Fatalf("expected APP_REPLICA_HOST to be required once the section is configured, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	urlType             = reflect.TypeOf(url.URL{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// decodeValue converts the raw string into the provided settable value. It supports the
+// common scalar kinds, durations, url.URL, comma separated slices, key=value maps and any
+// type implementing encoding.TextUnmarshaler (which covers time.Time in RFC 3339 format).
+func decodeValue(value reflect.Value, raw string) error {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return decodeValue(value.Elem(), raw)
+	}
+
+	if value.CanAddr() && value.Addr().Type().Implements(textUnmarshalerType) {
+		return value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	switch value.Type() {
+	case durationType:
+		parsed, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(parsed))
+		return nil
+	case urlType:
+		parsed, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(*parsed))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(raw), 0, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(strings.TrimSpace(raw), 0, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(raw), value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(parsed)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			value.SetBytes([]byte(raw))
+			return nil
+		}
+		return decodeSlice(value, raw)
+	case reflect.Map:
+		return decodeMap(value, raw)
+	default:
+		return fmt.Errorf("unsupported kind %s", value.Kind())
+	}
+
+	return nil
+}
+
+func decodeSlice(value reflect.Value, raw string) error {
+	items := splitList(raw)
+	slice := reflect.MakeSlice(value.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := decodeValue(slice.Index(i), item); err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+
+	value.Set(slice)
+	return nil
+}
+
+func decodeMap(value reflect.Value, raw string) error {
+	mapType := value.Type()
+	result := reflect.MakeMap(mapType)
+	for _, pair := range splitList(raw) {
+		rawKey, rawValue, found := strings.Cut(pair, "=")
+		if !found {
+			return errors.New("map entries must use the key=value format")
+		}
+
+		key := reflect.New(mapType.Key()).Elem()
+		if err := decodeValue(key, strings.TrimSpace(rawKey)); err != nil {
+			return fmt.Errorf("key %q: %w", rawKey, err)
+		}
+
+		item := reflect.New(mapType.Elem()).Elem()
+		if err := decodeValue(item, strings.TrimSpace(rawValue)); err != nil {
+			return fmt.Errorf("value for key %q: %w", rawKey, err)
+		}
+
+		result.SetMapIndex(key, item)
+	}
+
+	value.Set(result)
+	return nil
+}
+
+func splitList(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+
+	segments := strings.Split(raw, ",")
+	items := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			continue
+		}
+		items = append(items, segment)
+	}
+	return items
+}
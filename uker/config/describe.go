@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	fileSuffix   = "_FILE"
+	optionSecret = "secret"
+	redacted     = "******"
+)
+
+// SourceDefault is reported by Describe for values taken from a `default` tag.
+const SourceDefault = "default"
+
+// Entry describes the effective value of a configuration key and where it came from.
+type Entry struct {
+	Key    string
+	Type   string
+	Value  string
+	Source string
+	Secret bool
+}
+
+// Describe resolves the configured sources for the struct type of target and returns one
+// entry per key, in field order. Values of fields tagged `config:"name,secret"` are redacted.
+// Source is empty for keys without value.
+func (l Loader) Describe(target any) ([]Entry, error) {
+	typ, err := structType(target)
+	if err != nil {
+		return nil, err
+	}
+
+	bindings, err := l.bind(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(bindings))
+	for _, binding := range bindings {
+		entry := Entry{
+			Key:    binding.spec.key,
+			Type:   binding.spec.field.Type.String(),
+			Value:  binding.raw,
+			Source: binding.source,
+			Secret: binding.spec.tag.has(optionSecret),
+		}
+		if entry.Secret && entry.Value != "" {
+			entry.Value = redacted
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Dump writes the output of Describe as an aligned table, safe to log at startup.
+func (l Loader) Dump(w io.Writer, target any) error {
+	entries, err := l.Describe(target)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, entry := range entries {
+		source := entry.Source
+		if source == "" {
+			source = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", entry.Key, entry.Value, source)
+	}
+	return tw.Flush()
+}
+
+func readSecretFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	value := strings.TrimSuffix(string(content), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type secretConfig struct {
+	Host     string `config:"host" default:"localhost"`
+	Password string `config:"password,secret"`
+	Token    string `config:"token,secret"`
+	Port     int    `config:"port"`
+}
+
+func TestLoaderReadsFileSecrets(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "db_password", "s3cr3t\n")
+
+	t.Setenv("APP_PASSWORD_FILE", path)
+
+	var cfg secretConfig
+	if err := New("app").Load(&cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Password != "s3cr3t" {
+		t.Fatalf("Password = %q", cfg.Password)
+	}
+}
+
+func TestLoaderMissingSecretFile(t *testing.T) {
+	t.Setenv("APP_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	var cfg secretConfig
+	err := New("app").Load(&cfg)
+	if err == nil || !strings.Contains(err.Error(), "APP_PASSWORD_FILE") {
+		t.Fatalf("expected error naming APP_PASSWORD_FILE, got %v", err)
+	}
+}
+
+func TestDescribeRedactsSecrets(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "token", "abc\n")
+
+	loader := New("app", WithSources(Map(map[string]string{
+		"APP_PASSWORD":   "hunter2",
+		"APP_TOKEN_FILE": path,
+	})))
+
+	entries, err := loader.Describe(&secretConfig{})
+	if err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+
+	byKey := map[string]Entry{}
+	for _, entry := range entries {
+		byKey[entry.Key] = entry
+	}
+
+	if entry := byKey["APP_HOST"]; entry.Value != "localhost" || entry.Source != SourceDefault {
+		t.Fatalf("APP_HOST = %+v", entry)
+	}
+	if entry := byKey["APP_PASSWORD"]; entry.Value != redacted || entry.Source != "map" {
+		t.Fatalf("APP_PASSWORD = %+v", entry)
+	}
+	if entry := byKey["APP_TOKEN"]; entry.Value != redacted || entry.Source != "file:"+path {
+		t.Fatalf("APP_TOKEN = %+v", entry)
+	}
+	if entry := byKey["APP_PORT"]; entry.Source != "" {
+		t.Fatalf("APP_PORT = %+v", entry)
+	}
+
+	var buf bytes.Buffer
+	if err := loader.Dump(&buf, secretConfig{}); err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), "abc") {
+		t.Fatalf("dump leaked a secret:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DecodeError reports an environment value that cannot be converted into the field type.
+type DecodeError struct {
+	Key  string
+	Type reflect.Type
+	Err  error
+}
+
+// Error implements the error interface. The raw value is omitted on purpose so secrets
+// never end up in logs.
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("config: cannot decode %s into %s: %v", e.Key, e.Type, e.Err)
+}
+
+// Unwrap exposes the underlying parse error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// MissingError reports a required key that has no value and no default.
+type MissingError struct {
+	Key string
+}
+
+// Error implements the error interface.
+func (e *MissingError) Error() string {
+	return fmt.Sprintf("config: missing required variable %s", e.Key)
+}
+
+// ValidationError reports a decoded value that violates the rules of its `uker` tag.
+type ValidationError struct {
+	Key string
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("config: invalid value for %s: %v", e.Key, e.Err)
+}
+
+// Unwrap exposes the underlying validation error.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// LoadError aggregates every problem found by Load so a misconfigured deployment reports all
+// of them at once. Use errors.As to inspect the individual *DecodeError, *MissingError and
+// *ValidationError values.
+type LoadError struct {
+	Errors []error
+}
+
+// Error implements the error interface listing one problem per line.
+func (e *LoadError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	lines := make([]string, 0, len(e.Errors)+1)
+	lines = append(lines, fmt.Sprintf("config: %d problems found", len(e.Errors)))
+	for _, err := range e.Errors {
+		lines = append(lines, "  - "+strings.TrimPrefix(err.Error(), "config: "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap exposes the aggregated errors to errors.Is / errors.As.
+func (e *LoadError) Unwrap() []error {
+	return e.Errors
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+const (
+	tagName        = "config"
+	tagDefault     = "default"
+	optionSquash   = "squash"
+	optionRequired = "required"
+)
+
+// fieldSpec describes a single configurable leaf discovered while walking a struct type.
+type fieldSpec struct {
+	key          string
+	index        []int
+	field        reflect.StructField
+	tag          tagOptions
+	defaultValue string
+	hasDefault   bool
+}
+
+// tagOptions holds the parsed `config:"name,option..."` tag.
+type tagOptions struct {
+	name    string
+	skip    bool
+	options map[string]struct{}
+}
+
+func (o tagOptions) has(option string) bool {
+	_, ok := o.options[option]
+	return ok
+}
+
+func parseTag(field reflect.StructField) tagOptions {
+	raw, ok := field.Tag.Lookup(tagName)
+	if raw == "-" {
+		return tagOptions{skip: true}
+	}
+
+	opts := tagOptions{name: field.Name, options: map[string]struct{}{}}
+	if !ok {
+		return opts
+	}
+
+	parts := strings.Split(raw, ",")
+	if name := strings.TrimSpace(parts[0]); name != "" {
+		opts.name = name
+	}
+	for _, option := range parts[1:] {
+		option = strings.TrimSpace(option)
+		if option != "" {
+			opts.options[option] = struct{}{}
+		}
+	}
+
+	return opts
+}
+
+// collectFields walks the struct type recursively and returns every leaf field together with
+// the composed environment key. Nested and pointer-to-struct fields add their name as a key
+// segment, embedded structs add the type name unless tagged with `squash`, in which case
+// their fields are promoted to the parent level.
+func collectFields(typ reflect.Type, prefix string) []fieldSpec {
+	var specs []fieldSpec
+	walkStruct(typ, keySegments(prefix), nil, map[reflect.Type]bool{}, &specs)
+	return specs
+}
+
+func walkStruct(typ reflect.Type, segments []string, index []int, visiting map[reflect.Type]bool, specs *[]fieldSpec) {
+	if visiting[typ] {
+		return
+	}
+	visiting[typ] = true
+	defer delete(visiting, typ)
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() && (!field.Anonymous || field.Type.Kind() == reflect.Ptr) {
+			continue
+		}
+
+		tag := parseTag(field)
+		if tag.skip {
+			continue
+		}
+
+		fieldIndex := append(append([]int(nil), index...), i)
+		if structType, ok := nestedStruct(field.Type); ok {
+			nested := segments
+			if !tag.has(optionSquash) {
+				nested = append(append([]string(nil), segments...), tag.name)
+			}
+			walkStruct(structType, nested, fieldIndex, visiting, specs)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		defaultValue, hasDefault := field.Tag.Lookup(tagDefault)
+		*specs = append(*specs, fieldSpec{
+			key:          joinKey(append(append([]string(nil), segments...), tag.name)),
+			index:        fieldIndex,
+			field:        field,
+			tag:          tag,
+			defaultValue: defaultValue,
+			hasDefault:   hasDefault,
+		})
+	}
+}
+
+// nestedStruct reports whether the type is a struct (or pointer to struct) that should be
+// walked instead of decoded as a single value.
+func nestedStruct(typ reflect.Type) (reflect.Type, bool) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, false
+	}
+	if typ == urlType || reflect.PointerTo(typ).Implements(textUnmarshalerType) {
+		return nil, false
+	}
+	return typ, true
+}
+
+// fieldByIndex resolves the field identified by index, allocating nil pointers to structs
+// on the way so nested values can be assigned.
+func fieldByIndex(root reflect.Value, index []int) reflect.Value {
+	value := root
+	for _, i := range index {
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(i)
+	}
+	return value
+}
+
+func keySegments(prefix string) []string {
+	if prefix == "" {
+		return nil
+	}
+	return []string{prefix}
+}
+
+func joinKey(segments []string) string {
+	return strings.ToUpper(strings.Join(segments, "_"))
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type dotEnvSource struct {
+	path string
+}
+
+// DotEnvFile returns a Source reading KEY=VALUE lines from the given .env file. Keys are full
+// environment keys. A missing file yields no values so the same stack works in environments
+// where the file is not deployed.
+func DotEnvFile(path string) Source {
+	return dotEnvSource{path: path}
+}
+
+func (s dotEnvSource) Name() string {
+	return "file:" + s.path
+}
+
+func (s dotEnvSource) Values(string) (map[string]string, error) {
+	content, err := readOptionalFile(s.path)
+	if err != nil || content == nil {
+		return nil, err
+	}
+	return parseDotEnv(content)
+}
+
+type jsonSource struct {
+	path string
+}
+
+// JSONFile returns a Source reading a JSON document. Nested objects compose the key relative
+// to the loader prefix, so {"db": {"host": "x"}} provides PREFIX_DB_HOST. Arrays are joined
+// with commas to match the slice decoding rules. A missing file yields no values.
+func JSONFile(path string) Source {
+	return jsonSource{path: path}
+}
+
+func (s jsonSource) Name() string {
+	return "file:" + s.path
+}
+
+func (s jsonSource) Values(prefix string) (map[string]string, error) {
+	content, err := readOptionalFile(s.path)
+	if err != nil || content == nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	var document map[string]any
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	values := map[string]string{}
+	if err := flattenJSON(document, keySegments(prefix), values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+type profileSource struct {
+	envVar string
+	path   string
+}
+
+// Profile returns a Source that overlays a profile specific variant of path selected by the
+// envVar environment variable. With APP_PROFILE=production, Profile("APP_PROFILE",
+// "config.json") reads config.production.json. The file format follows the extension
+// (".json" for JSON, anything else as a .env file). When envVar is unset the source is empty.
+func Profile(envVar string, path string) Source {
+	return profileSource{envVar: envVar, path: path}
+}
+
+func (s profileSource) Name() string {
+	if path := s.profilePath(); path != "" {
+		return "file:" + path
+	}
+	return "profile:" + s.envVar
+}
+
+func (s profileSource) Values(prefix string) (map[string]string, error) {
+	path := s.profilePath()
+	if path == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return JSONFile(path).Values(prefix)
+	}
+	return DotEnvFile(path).Values(prefix)
+}
+
+func (s profileSource) profilePath() string {
+	profile := strings.TrimSpace(os.Getenv(s.envVar))
+	if profile == "" {
+		return ""
+	}
+
+	ext := filepath.Ext(s.path)
+	return strings.TrimSuffix(s.path, ext) + "." + profile + ext
+}
+
+func readOptionalFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return content, nil
+}
+
+func parseDotEnv(content []byte) (map[string]string, error) {
+	values := map[string]string{}
+	for number, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, raw, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", number+1)
+		}
+
+		value, err := parseDotEnvValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number+1, err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+func parseDotEnvValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	switch raw[0] {
+	case '"':
+		end := strings.LastIndex(raw, `"`)
+		if end == 0 {
+			return "", errors.New("unterminated double quoted value")
+		}
+		return strconv.Unquote(raw[:end+1])
+	case '\'':
+		end := strings.LastIndex(raw, "'")
+		if end == 0 {
+			return "", errors.New("unterminated single quoted value")
+		}
+		return raw[1:end], nil
+	}
+
+	if idx := strings.Index(raw, " #"); idx >= 0 {
+		raw = raw[:idx]
+	}
+	return strings.TrimSpace(raw), nil
+}
+
+func flattenJSON(value any, segments []string, values map[string]string) error {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, item := range typed {
+			if err := flattenJSON(item, append(append([]string(nil), segments...), key), values); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		items := make([]string, 0, len(typed))
+		for _, item := range typed {
+			switch item.(type) {
+			case map[string]any, []any:
+				return fmt.Errorf("%s: arrays may only contain scalar values", joinKey(segments))
+			}
+			items = append(items, jsonScalar(item))
+		}
+		values[joinKey(segments)] = strings.Join(items, ",")
+		return nil
+	case nil:
+		return nil
+	default:
+		values[joinKey(segments)] = jsonScalar(typed)
+		return nil
+	}
+}
+
+func jsonScalar(value any) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
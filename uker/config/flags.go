@@ -0,0 +1,86 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// BindFlags registers one flag per key of target on fs and returns a Loader that gives those
+// flags the highest precedence. Flag names derive from the key without the prefix, so
+// PREFIX_DB_HOST becomes -db-host, and the help text comes from the `desc` tag. Only flags
+// explicitly set on the command line override the other sources, so fs must be parsed
+// before calling Load on the returned Loader.
+func (l Loader) BindFlags(fs *flag.FlagSet, target any) (Loader, error) {
+	typ, err := structType(target)
+	if err != nil {
+		return l, err
+	}
+
+	source := flagSource{fs: fs, keys: map[string]string{}}
+	for _, spec := range collectFields(typ, l.prefix) {
+		name := flagName(l.prefix, spec.key)
+		if fs.Lookup(name) != nil {
+			return l, fmt.Errorf("config: flag -%s already defined", name)
+		}
+
+		value := &flagValue{raw: spec.defaultValue, isBool: spec.field.Type.Kind() == reflect.Bool}
+		fs.Var(value, name, spec.field.Tag.Get(tagDescription))
+		source.keys[name] = spec.key
+	}
+
+	bound := l
+	bound.sources = append(append([]Source(nil), l.sources...), source)
+	return bound, nil
+}
+
+// flagName converts PREFIX_DB_HOST into db-host.
+func flagName(prefix, key string) string {
+	if prefix != "" {
+		key = strings.TrimPrefix(key, strings.ToUpper(prefix)+"_")
+	}
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+type flagSource struct {
+	fs   *flag.FlagSet
+	keys map[string]string
+}
+
+func (flagSource) Name() string {
+	return "flag"
+}
+
+func (s flagSource) Values(string) (map[string]string, error) {
+	values := map[string]string{}
+	s.fs.Visit(func(f *flag.Flag) {
+		if key, ok := s.keys[f.Name]; ok {
+			values[key] = f.Value.String()
+		}
+	})
+	return values, nil
+}
+
+// flagValue stores the raw flag text; decoding happens in Load like for any other source.
+type flagValue struct {
+	raw    string
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.raw
+}
+
+func (v *flagValue) Set(raw string) error {
+	v.raw = raw
+	return nil
+}
+
+// IsBoolFlag lets boolean fields be set with a bare -name.
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
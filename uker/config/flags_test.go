@@ -0,0 +1,68 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+type flagConfig struct {
+	DB    serverConfig `config:"db"`
+	Debug bool         `config:"debug" desc:"Enable debug mode"`
+	Name  string       `config:"name" default:"svc"`
+}
+
+func TestBindFlagsOverridesEnvironment(t *testing.T) {
+	t.Setenv("APP_DB_HOST", "env-host")
+	t.Setenv("APP_DB_PORT", "3306")
+
+	fs := flag.NewFlagSet("maintenance", flag.ContinueOnError)
+	var cfg flagConfig
+	loader, err := New("app").BindFlags(fs, &cfg)
+	if err != nil {
+		t.Fatalf("BindFlags: %v", err)
+	}
+
+	if err := fs.Parse([]string{"--db-host", "flag-host", "-debug"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if err := loader.Load(&cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.DB.Host != "flag-host" {
+		t.Fatalf("DB.Host = %q", cfg.DB.Host)
+	}
+	if cfg.DB.Port != 3306 {
+		t.Fatalf("DB.Port = %d", cfg.DB.Port)
+	}
+	if !cfg.Debug {
+		t.Fatalf("expected Debug to be set by flag")
+	}
+	if cfg.Name != "svc" {
+		t.Fatalf("Name = %q", cfg.Name)
+	}
+}
+
+func TestBindFlagsHelpUsesDescription(t *testing.T) {
+	fs := flag.NewFlagSet("maintenance", flag.ContinueOnError)
+	if _, err := New("app").BindFlags(fs, &flagConfig{}); err != nil {
+		t.Fatalf("BindFlags: %v", err)
+	}
+
+	var help strings.Builder
+	fs.SetOutput(&help)
+	fs.PrintDefaults()
+	if !strings.Contains(help.String(), "-debug") || !strings.Contains(help.String(), "Enable debug mode") {
+		t.Fatalf("unexpected help:\n%s", help.String())
+	}
+	if !strings.Contains(help.String(), "(default svc)") {
+		t.Fatalf("expected default in help:\n%s", help.String())
+	}
+
+	fs.SetOutput(io.Discard)
+	if _, err := New("app").BindFlags(fs, &flagConfig{}); err == nil {
+		t.Fatalf("expected error when flags are already defined")
+	}
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+const tagDescription = "desc"
+
+// Reference describes a configuration key as declared by the struct tags. It is the model
+// used by WriteEnvExample and WriteMarkdown.
+type Reference struct {
+	Key         string
+	Type        string
+	Default     string
+	HasDefault  bool
+	Required    bool
+	Secret      bool
+	Description string
+}
+
+// References returns one Reference per key of the struct type of target, in field order.
+func (l Loader) References(target any) ([]Reference, error) {
+	typ, err := structType(target)
+	if err != nil {
+		return nil, err
+	}
+
+	specs := collectFields(typ, l.prefix)
+	references := make([]Reference, 0, len(specs))
+	for _, spec := range specs {
+		references = append(references, Reference{
+			Key:         spec.key,
+			Type:        spec.field.Type.String(),
+			Default:     spec.defaultValue,
+			HasDefault:  spec.hasDefault,
+			Required:    spec.tag.has(optionRequired),
+			Secret:      spec.tag.has(optionSecret),
+			Description: spec.field.Tag.Get(tagDescription),
+		})
+	}
+	return references, nil
+}
+
+// WriteEnvExample writes a .env.example file for target. Every key is preceded by comments
+// with its description, type and flags, and is assigned its default value (if any).
+func (l Loader) WriteEnvExample(w io.Writer, target any) error {
+	references, err := l.References(target)
+	if err != nil {
+		return err
+	}
+
+	for i, ref := range references {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if ref.Description != "" {
+			if _, err := fmt.Fprintf(w, "# %s\n", ref.Description); err != nil {
+				return err
+			}
+		}
+
+		details := []string{"type: " + ref.Type}
+		if ref.Required {
+			details = append(details, "required")
+		}
+		if ref.Secret {
+			details = append(details, "secret")
+		}
+		if _, err := fmt.Fprintf(w, "# %s\n%s=%s\n", strings.Join(details, ", "), ref.Key, ref.Default); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMarkdown writes a markdown table documenting every key of target.
+func (l Loader) WriteMarkdown(w io.Writer, target any) error {
+	references, err := l.References(target)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{
+		"| Key | Type | Default | Required | Description |",
+		"| --- | --- | --- | --- | --- |",
+	}
+	for _, ref := range references {
+		defaultValue := ""
+		if ref.HasDefault {
+			defaultValue = "`" + ref.Default + "`"
+		}
+		required := "no"
+		if ref.Required {
+			required = "yes"
+		}
+		lines = append(lines, fmt.Sprintf("| `%s` | `%s` | %s | %s | %s |",
+			ref.Key, ref.Type, escapeMarkdown(defaultValue), required, escapeMarkdown(ref.Description)))
+	}
+
+	_, err = fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+func structType(target any) (reflect.Type, error) {
+	typ := reflect.TypeOf(target)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("target must be a struct or a pointer to a struct")
+	}
+	return typ, nil
+}
+
+func escapeMarkdown(value string) string {
+	return strings.ReplaceAll(value, "|", `\|`)
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+)
+
+type documentedConfig struct {
+	Host    string `config:"host,required" desc:"Database host"`
+	Port    int    `config:"port" default:"3306" desc:"Database port"`
+	Pass    string `config:"password,secret"`
+	Comment string `config:"comment" desc:"Free text | with pipe"`
+}
+
+func TestWriteEnvExample(t *testing.T) {
+	var buf bytes.Buffer
+	if err := New("app").WriteEnvExample(&buf, documentedConfig{}); err != nil {
+		t.Fatalf("WriteEnvExample: %v", err)
+	}
+
+	expected := `# Database host
+# type: string, required
+APP_HOST=
+
+# Database port
+# type: int
+APP_PORT=3306
+
+# type: string, secret
+APP_PASSWORD=
+
+# Free text | with pipe
+# type: string
+APP_COMMENT=
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected .env.example:\n%s", buf.String())
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := New("app").WriteMarkdown(&buf, &documentedConfig{}); err != nil {
+		t.Fatalf("WriteMarkdown: %v", err)
+	}
+
+	expected := "| Key | Type | Default | Required | Description |\n" +
+		"| --- | --- | --- | --- | --- |\n" +
+		"| `APP_HOST` | `string` |  | yes | Database host |\n" +
+		"| `APP_PORT` | `int` | `3306` | no | Database port |\n" +
+		"| `APP_PASSWORD` | `string` |  | no |  |\n" +
+		"| `APP_COMMENT` | `string` |  | no | Free text \\| with pipe |\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected markdown:\n%s", buf.String())
+	}
+}
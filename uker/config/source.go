@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Source provides raw configuration values. Loader queries every source on each Load so
+// file based sources always reflect the current content on disk.
+type Source interface {
+	// Name identifies the source in dumps and error messages.
+	Name() string
+	// Values returns the raw values keyed by environment key. The loader prefix is provided
+	// so sources with relative keys (e.g. JSON documents) can compose the full key.
+	Values(prefix string) (map[string]string, error)
+}
+
+// Option configures a Loader.
+type Option func(*Loader)
+
+// WithSources replaces the default environment source with the provided sources. Sources
+// are listed from lowest to highest precedence: a key found in a later source overrides the
+// same key from an earlier one, and every source overrides `default` tags.
+func WithSources(sources ...Source) Option {
+	return func(l *Loader) {
+		l.sources = append([]Source(nil), sources...)
+	}
+}
+
+type envSource struct{}
+
+// Env returns a Source reading the process environment.
+func Env() Source {
+	return envSource{}
+}
+
+func (envSource) Name() string {
+	return "env"
+}
+
+func (envSource) Values(string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, entry := range os.Environ() {
+		if key, value, found := strings.Cut(entry, "="); found {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+type mapSource struct {
+	values map[string]string
+}
+
+// Map returns a Source backed by the provided map. Keys are full environment keys (including
+// the prefix). It is mostly useful in tests.
+func Map(values map[string]string) Source {
+	copied := make(map[string]string, len(values))
+	for key, value := range values {
+		copied[key] = value
+	}
+	return mapSource{values: copied}
+}
+
+func (mapSource) Name() string {
+	return "map"
+}
+
+func (s mapSource) Values(string) (map[string]string, error) {
+	return s.values, nil
+}
+
+// resolvedValue is a raw value together with the name of the source that provided it.
+type resolvedValue struct {
+	value  string
+	source string
+	rank   int
+}
+
+// resolve merges every source following their precedence order.
+func (l Loader) resolve() (map[string]resolvedValue, error) {
+	merged := map[string]resolvedValue{}
+	for rank, source := range l.sources {
+		values, err := source.Values(l.prefix)
+		if err != nil {
+			return nil, fmt.Errorf("config: source %s: %w", source.Name(), err)
+		}
+		for key, value := range values {
+			merged[strings.ToUpper(key)] = resolvedValue{value: value, source: source.Name(), rank: rank}
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type layeredConfig struct {
+	Name string       `config:"name" default:"svc"`
+	DB   serverConfig `config:"db"`
+	Tags []string     `config:"tags"`
+	Mode string       `config:"mode"`
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoaderLayeredSources(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := writeFile(t, dir, "config.json", `{"db": {"host": "file-host", "port": 3306}, "tags": ["a", "b"], "mode": "file"}`)
+	writeFile(t, dir, "config.production.json", `{"db": {"host": "prod-host"}}`)
+	envPath := writeFile(t, dir, ".env", "# local overrides\nexport APP_DB_PORT=3307\nAPP_MODE=\"dotenv\" # quoted\n")
+
+	t.Setenv("APP_PROFILE", "production")
+	t.Setenv("APP_MODE", "env")
+
+	loader := New("app", WithSources(
+		JSONFile(jsonPath),
+		Profile("APP_PROFILE", jsonPath),
+		DotEnvFile(envPath),
+		Env(),
+	))
+
+	var cfg layeredConfig
+	if err := loader.Load(&cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Name != "svc" {
+		t.Fatalf("Name = %q", cfg.Name)
+	}
+	if cfg.DB.Host != "prod-host" {
+		t.Fatalf("DB.Host = %q", cfg.DB.Host)
+	}
+	if cfg.DB.Port != 3307 {
+		t.Fatalf("DB.Port = %d", cfg.DB.Port)
+	}
+	if len(cfg.Tags) != 2 || cfg.Tags[1] != "b" {
+		t.Fatalf("Tags = %v", cfg.Tags)
+	}
+	if cfg.Mode != "env" {
+		t.Fatalf("Mode = %q", cfg.Mode)
+	}
+}
+
+func TestLoaderMissingFilesAreIgnored(t *testing.T) {
+	dir := t.TempDir()
+	loader := New("app", WithSources(
+		JSONFile(filepath.Join(dir, "missing.json")),
+		DotEnvFile(filepath.Join(dir, ".env")),
+		Map(map[string]string{"APP_MODE": "map"}),
+	))
+
+	var cfg layeredConfig
+	if err := loader.Load(&cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Mode != "map" {
+		t.Fatalf("Mode = %q", cfg.Mode)
+	}
+}
+
+func TestLoaderMalformedFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, ".env", "NOT A PAIR\n")
+
+	var cfg layeredConfig
+	if err := New("app", WithSources(DotEnvFile(path))).Load(&cfg); err == nil {
+		t.Fatalf("expected error for malformed .env file")
+	}
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	optionNoReload = "noreload"
+
+	defaultWatchInterval = 2 * time.Second
+)
+
+// Change describes a successful reload delivered to Watcher subscribers.
+type Change[T any] struct {
+	// Old is the snapshot replaced by the reload.
+	Old *T
+	// New is the snapshot now returned by Watcher.Current.
+	New *T
+	// Changed lists the keys whose new value was applied.
+	Changed []string
+	// Skipped lists keys tagged `config:"name,noreload"` whose value changed in the sources
+	// but was kept from the previous snapshot.
+	Skipped []string
+}
+
+// WatchOption configures a Watcher.
+type WatchOption func(*watchConfig)
+
+type watchConfig struct {
+	interval time.Duration
+	onError  func(error)
+}
+
+// WithInterval sets how often the sources are polled for changes.
+func WithInterval(interval time.Duration) WatchOption {
+	return func(cfg *watchConfig) {
+		if interval > 0 {
+			cfg.interval = interval
+		}
+	}
+}
+
+// WithErrorHandler registers a callback for reloads that fail. The previous snapshot is kept.
+func WithErrorHandler(handler func(error)) WatchOption {
+	return func(cfg *watchConfig) {
+		cfg.onError = handler
+	}
+}
+
+// Watcher keeps an atomically swapped snapshot of a configuration struct and reloads it
+// when its sources change. It is safe for concurrent use.
+type Watcher[T any] struct {
+	loader  Loader
+	config  watchConfig
+	current atomic.Pointer[T]
+
+	mu          sync.Mutex
+	last        map[string]binding
+	subscribers map[int]func(Change[T])
+	nextID      int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Watch loads T with the loader and starts polling its sources until ctx is cancelled or
+// Close is called. The initial load must succeed; later failures keep the previous snapshot
+// and are reported to the WithErrorHandler callback.
+func Watch[T any](ctx context.Context, loader Loader, opts ...WatchOption) (*Watcher[T], error) {
+	cfg := watchConfig{interval: defaultWatchInterval}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+
+	w := &Watcher[T]{
+		loader:      loader,
+		config:      cfg,
+		subscribers: map[int]func(Change[T]){},
+		done:        make(chan struct{}),
+	}
+
+	initial, bindings, err := w.load(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	w.current.Store(initial)
+	w.last = indexBindings(bindings)
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	go w.poll(ctx)
+	return w, nil
+}
+
+// Current returns the latest snapshot. Callers must treat it as read-only.
+func (w *Watcher[T]) Current() *T {
+	return w.current.Load()
+}
+
+// Subscribe registers fn to be called after every reload that changes at least one key. The
+// returned function removes the subscription.
+func (w *Watcher[T]) Subscribe(fn func(Change[T])) func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	id := w.nextID
+	w.nextID++
+	w.subscribers[id] = fn
+
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.subscribers, id)
+	}
+}
+
+// Reload re-reads the sources immediately. It returns nil without notifying subscribers when
+// nothing changed.
+func (w *Watcher[T]) Reload() error {
+	w.mu.Lock()
+
+	bindings, err := w.loader.bind(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		w.mu.Unlock()
+		return err
+	}
+
+	changed, skipped := diffBindings(w.last, bindings)
+	if len(changed) == 0 && len(skipped) == 0 {
+		w.mu.Unlock()
+		return nil
+	}
+
+	old := w.current.Load()
+	next, _, err := w.load(old, bindings)
+	if err != nil {
+		w.mu.Unlock()
+		return err
+	}
+
+	w.current.Store(next)
+	w.last = indexBindings(bindings)
+	subscribers := make([]func(Change[T]), 0, len(w.subscribers))
+	for _, fn := range w.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	w.mu.Unlock()
+
+	change := Change[T]{Old: old, New: next, Changed: changed, Skipped: skipped}
+	for _, fn := range subscribers {
+		fn(change)
+	}
+	return nil
+}
+
+// Close stops polling and waits for the watcher goroutine to exit.
+func (w *Watcher[T]) Close() {
+	w.cancel()
+	<-w.done
+}
+
+func (w *Watcher[T]) poll(ctx context.Context) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.config.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.Reload(); err != nil && w.config.onError != nil {
+				w.config.onError(err)
+			}
+		}
+	}
+}
+
+// load decodes a fresh T. When old is provided, fields tagged noreload keep their old value.
+func (w *Watcher[T]) load(old *T, bindings []binding) (*T, []binding, error) {
+	next := new(T)
+	elem := reflect.ValueOf(next).Elem()
+	if elem.Kind() != reflect.Struct {
+		return nil, nil, errors.New("config: Watch requires a struct type")
+	}
+
+	if bindings == nil {
+		var err error
+		if bindings, err = w.loader.bind(elem.Type()); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := apply(elem, bindings); err != nil {
+		return nil, nil, err
+	}
+
+	if old != nil {
+		oldElem := reflect.ValueOf(old).Elem()
+		for _, binding := range bindings {
+			if !binding.spec.tag.has(optionNoReload) {
+				continue
+			}
+			if previous, ok := readField(oldElem, binding.spec.index); ok {
+				fieldByIndex(elem, binding.spec.index).Set(previous)
+			} else {
+				fieldByIndex(elem, binding.spec.index).SetZero()
+			}
+		}
+	}
+
+	return next, bindings, nil
+}
+
+func indexBindings(bindings []binding) map[string]binding {
+	indexed := make(map[string]binding, len(bindings))
+	for _, binding := range bindings {
+		indexed[binding.spec.key] = binding
+	}
+	return indexed
+}
+
+func diffBindings(previous map[string]binding, current []binding) (changed, skipped []string) {
+	for _, binding := range current {
+		before, ok := previous[binding.spec.key]
+		if ok && before.raw == binding.raw && before.found == binding.found {
+			continue
+		}
+		if binding.spec.tag.has(optionNoReload) {
+			skipped = append(skipped, binding.spec.key)
+			continue
+		}
+		changed = append(changed, binding.spec.key)
+	}
+	return changed, skipped
+}
+
+// readField resolves the field identified by index without allocating. It reports false when
+// a nil pointer is found on the way.
+func readField(root reflect.Value, index []int) (reflect.Value, bool) {
+	value := root
+	for _, i := range index {
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return reflect.Value{}, false
+			}
+			value = value.Elem()
+		}
+		value = value.Field(i)
+	}
+	return value, true
+}
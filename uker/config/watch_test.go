@@ -0,0 +1,79 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type reloadConfig struct {
+	Level    string `config:"level"`
+	Rate     int    `config:"rate"`
+	Password string `config:"password,noreload"`
+}
+
+func TestWatchReloadsChangedSources(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, ".env", "APP_LEVEL=info\nAPP_RATE=10\nAPP_PASSWORD=first\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher, err := Watch[reloadConfig](ctx, New("app", WithSources(DotEnvFile(path))), WithInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	defer watcher.Close()
+
+	if current := watcher.Current(); current.Level != "info" || current.Rate != 10 {
+		t.Fatalf("initial snapshot = %+v", current)
+	}
+
+	changes := make(chan Change[reloadConfig], 1)
+	watcher.Subscribe(func(change Change[reloadConfig]) {
+		changes <- change
+	})
+
+	writeFile(t, dir, ".env", "APP_LEVEL=debug\nAPP_RATE=10\nAPP_PASSWORD=second\n")
+
+	select {
+	case change := <-changes:
+		if change.Old.Level != "info" || change.New.Level != "debug" {
+			t.Fatalf("unexpected change: old=%+v new=%+v", change.Old, change.New)
+		}
+		if len(change.Changed) != 1 || change.Changed[0] != "APP_LEVEL" {
+			t.Fatalf("Changed = %v", change.Changed)
+		}
+		if len(change.Skipped) != 1 || change.Skipped[0] != "APP_PASSWORD" {
+			t.Fatalf("Skipped = %v", change.Skipped)
+		}
+		if change.New.Password != "first" {
+			t.Fatalf("expected non-reloadable field to be kept, got %q", change.New.Password)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for reload")
+	}
+
+	if watcher.Current().Level != "debug" {
+		t.Fatalf("Current().Level = %q", watcher.Current().Level)
+	}
+}
+
+func TestWatchKeepsSnapshotOnInvalidReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, ".env", "APP_RATE=10\n")
+
+	watcher, err := Watch[reloadConfig](context.Background(), New("app", WithSources(DotEnvFile(path))), WithInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	defer watcher.Close()
+
+	writeFile(t, dir, ".env", "APP_RATE=fast\n")
+	if err := watcher.Reload(); err == nil {
+		t.Fatalf("expected reload error")
+	}
+	if watcher.Current().Rate != 10 {
+		t.Fatalf("Rate = %d", watcher.Current().Rate)
+	}
+}
@@ -0,0 +1,441 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// DefaultHistoryTable stores the ChangeRecord rows written by the audit plugin.
+const DefaultHistoryTable = "change_history"
+
+// Audit column names filled by the audit plugin.
+const (
+	ColumnCreatedBy = "created_by"
+	ColumnUpdatedBy = "updated_by"
+	ColumnDeletedBy = "deleted_by"
+)
+
+// Change history actions.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+const (
+	auditPluginName = "uker:audit"
+	auditBeforeKey  = "uker:audit:before"
+)
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx identifying who performs the following writes, usually the
+// authenticated user id set by an HTTP middleware.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored with WithActor.
+func ActorFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok && actor != ""
+}
+
+// AuditFields can be embedded in models to get the columns filled by the audit plugin. Any
+// model declaring columns with the same names works as well.
+type AuditFields struct {
+	CreatedBy string `gorm:"column:created_by;size:128" json:"created_by,omitempty"`
+	UpdatedBy string `gorm:"column:updated_by;size:128" json:"updated_by,omitempty"`
+	DeletedBy string `gorm:"column:deleted_by;size:128" json:"deleted_by,omitempty"`
+}
+
+// ChangeRecord is a row of the change history. Before and After hold JSON objects with the
+// columns that changed; Before is empty for creations and After for deletions.
+type ChangeRecord struct {
+	ID        uint64    `gorm:"column:id;primaryKey"`
+	Table     string    `gorm:"column:table_name;size:128;index:idx_change_history_record"`
+	RecordID  string    `gorm:"column:record_id;size:128;index:idx_change_history_record"`
+	Action    string    `gorm:"column:action;size:16"`
+	Actor     string    `gorm:"column:actor;size:128"`
+	Before    string    `gorm:"column:before;type:text"`
+	After     string    `gorm:"column:after;type:text"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+// AuditConfig configures the plugin returned by NewAuditPlugin.
+type AuditConfig struct {
+	// History writes a ChangeRecord for every row created, updated or deleted through GORM.
+	// The table is created when the plugin is registered.
+	History bool
+	// HistoryTable overrides DefaultHistoryTable.
+	HistoryTable string
+}
+
+type auditPlugin struct {
+	config AuditConfig
+}
+
+// NewAuditPlugin returns a GORM plugin, registered with gorm.DB.Use, that fills created_by and
+// updated_by with the actor of the context, sets deleted_by alongside soft deletes and,
+// optionally, records a change history.
+func NewAuditPlugin(config AuditConfig) gorm.Plugin {
+	if config.HistoryTable == "" {
+		config.HistoryTable = DefaultHistoryTable
+	}
+	return &auditPlugin{config: config}
+}
+
+func (p *auditPlugin) Name() string {
+	return auditPluginName
+}
+
+func (p *auditPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("gorm:create").Register(auditPluginName+":create", p.beforeCreate); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register(auditPluginName+":update", p.beforeUpdate); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register(auditPluginName+":delete", p.beforeDelete); err != nil {
+		return err
+	}
+	if !p.config.History {
+		return nil
+	}
+
+	if err := db.WithContext(UsePrimary(db.Statement.Context)).Table(p.config.HistoryTable).AutoMigrate(&ChangeRecord{}); err != nil {
+		return err
+	}
+	if err := callbacks.Create().After("gorm:create").Register(auditPluginName+":create_history", p.afterCreate); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register(auditPluginName+":update_history", p.afterWrite(ActionUpdate)); err != nil {
+		return err
+	}
+	return callbacks.Delete().After("gorm:delete").Register(auditPluginName+":delete_history", p.afterWrite(ActionDelete))
+}
+
+func (p *auditPlugin) skip(db *gorm.DB) bool {
+	return db.Error != nil || db.Statement.Schema == nil || db.Statement.Table == p.config.HistoryTable
+}
+
+func (p *auditPlugin) beforeCreate(db *gorm.DB) {
+	if p.skip(db) {
+		return
+	}
+	actor, ok := ActorFromContext(db.Statement.Context)
+	if !ok {
+		return
+	}
+
+	for _, column := range []string{ColumnCreatedBy, ColumnUpdatedBy} {
+		if field := db.Statement.Schema.LookUpField(column); field != nil {
+			setIfZero(db.Statement, field, actor)
+		}
+	}
+}
+
+func (p *auditPlugin) beforeUpdate(db *gorm.DB) {
+	if p.skip(db) {
+		return
+	}
+	if p.config.History {
+		p.snapshot(db)
+	}
+
+	actor, ok := ActorFromContext(db.Statement.Context)
+	if !ok || db.Statement.Schema.LookUpField(ColumnUpdatedBy) == nil {
+		return
+	}
+
+	stmt := db.Statement
+	stmt.SetColumn(ColumnUpdatedBy, actor, true)
+	if len(stmt.Selects) > 0 && !slices.Contains(stmt.Selects, "*") && !slices.Contains(stmt.Selects, ColumnUpdatedBy) {
+		stmt.Selects = append(stmt.Selects, ColumnUpdatedBy)
+	}
+}
+
+func (p *auditPlugin) beforeDelete(db *gorm.DB) {
+	if p.skip(db) {
+		return
+	}
+	if p.config.History {
+		p.snapshot(db)
+	}
+
+	stmt := db.Statement
+	if stmt.Unscoped || stmt.SQL.Len() > 0 {
+		return
+	}
+	deletedBy := stmt.Schema.LookUpField(ColumnDeletedBy)
+	deletedAt := softDeleteField(stmt.Schema)
+	if deletedBy == nil || deletedAt == nil {
+		return
+	}
+	actor, ok := ActorFromContext(stmt.Context)
+	if !ok {
+		return
+	}
+
+	// GORM's soft delete builds its UPDATE with a single SET assignment, so the statement is
+	// built here with both columns and the soft delete clause becomes a no-op.
+	now := db.NowFunc()
+	stmt.AddClause(clause.Set{
+		{Column: clause.Column{Name: deletedAt.DBName}, Value: now},
+		{Column: clause.Column{Name: deletedBy.DBName}, Value: actor},
+	})
+	stmt.SetColumn(deletedAt.DBName, now, true)
+	stmt.SetColumn(deletedBy.DBName, actor, true)
+	if column, values := primaryKeyCondition(stmt, stmt.ReflectValue); len(values) > 0 {
+		stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.IN{Column: column, Values: values}}})
+	}
+	if stmt.ReflectValue.CanAddr() && stmt.Dest != stmt.Model && stmt.Model != nil {
+		if column, values := primaryKeyCondition(stmt, reflect.ValueOf(stmt.Model)); len(values) > 0 {
+			stmt.AddClause(clause.Where{Exprs: []clause.Expression{clause.IN{Column: column, Values: values}}})
+		}
+	}
+
+	gorm.SoftDeleteQueryClause{Field: deletedAt}.ModifyStatement(stmt)
+	stmt.AddClauseIfNotExists(clause.Update{})
+	stmt.Build(db.Callback().Update().Clauses...)
+}
+
+// snapshot loads the rows an update or delete is about to change so the history can record
+// their previous values.
+func (p *auditPlugin) snapshot(db *gorm.DB) {
+	stmt := db.Statement
+	if stmt.DryRun || len(stmt.Schema.PrimaryFields) == 0 {
+		return
+	}
+
+	query := db.Session(&gorm.Session{NewDB: true}).Table(stmt.Table)
+	filtered := false
+	if where, ok := stmt.Clauses["WHERE"]; ok && where.Expression != nil {
+		query = query.Clauses(where.Expression)
+		filtered = true
+	}
+	if column, values := primaryKeyCondition(stmt, stmt.ReflectValue); len(values) > 0 {
+		query = query.Where(clause.IN{Column: column, Values: values})
+		filtered = true
+	}
+	if !filtered {
+		return
+	}
+
+	var rows []map[string]any
+	if err := query.Find(&rows).Error; err != nil {
+		db.AddError(err)
+		return
+	}
+	stmt.Settings.Store(auditBeforeKey, rows)
+}
+
+func (p *auditPlugin) afterCreate(db *gorm.DB) {
+	if p.skip(db) || db.RowsAffected == 0 || len(db.Statement.Schema.PrimaryFields) == 0 {
+		return
+	}
+
+	stmt := db.Statement
+	column, values := primaryKeyCondition(stmt, stmt.ReflectValue)
+	if len(values) == 0 {
+		return
+	}
+
+	var rows []map[string]any
+	if err := db.Session(&gorm.Session{NewDB: true}).Table(stmt.Table).Where(clause.IN{Column: column, Values: values}).Find(&rows).Error; err != nil {
+		db.AddError(err)
+		return
+	}
+
+	records := make([]ChangeRecord, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, p.record(db, ActionCreate, row, nil, row))
+	}
+	p.write(db, records)
+}
+
+func (p *auditPlugin) afterWrite(action string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if p.skip(db) || db.RowsAffected == 0 {
+			return
+		}
+
+		stmt := db.Statement
+		stored, ok := stmt.Settings.LoadAndDelete(auditBeforeKey)
+		if !ok {
+			return
+		}
+		before := stored.([]map[string]any)
+		if len(before) == 0 {
+			return
+		}
+
+		after := map[string]map[string]any{}
+		if action == ActionUpdate {
+			keys := make([][]any, 0, len(before))
+			for _, row := range before {
+				keys = append(keys, primaryKeyValues(stmt.Schema, row))
+			}
+			column, values := schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, keys)
+
+			var rows []map[string]any
+			if err := db.Session(&gorm.Session{NewDB: true}).Table(stmt.Table).Where(clause.IN{Column: column, Values: values}).Find(&rows).Error; err != nil {
+				db.AddError(err)
+				return
+			}
+			for _, row := range rows {
+				after[recordID(stmt.Schema, row)] = row
+			}
+		}
+
+		var records []ChangeRecord
+		for _, old := range before {
+			if action == ActionDelete {
+				records = append(records, p.record(db, action, old, old, nil))
+				continue
+			}
+
+			current, found := after[recordID(stmt.Schema, old)]
+			if !found {
+				continue
+			}
+			oldDiff, newDiff := diffRows(old, current)
+			if len(newDiff) == 0 {
+				continue
+			}
+			records = append(records, p.record(db, action, old, oldDiff, newDiff))
+		}
+		p.write(db, records)
+	}
+}
+
+func (p *auditPlugin) record(db *gorm.DB, action string, row, before, after map[string]any) ChangeRecord {
+	actor, _ := ActorFromContext(db.Statement.Context)
+	return ChangeRecord{
+		Table:     db.Statement.Table,
+		RecordID:  recordID(db.Statement.Schema, row),
+		Action:    action,
+		Actor:     actor,
+		Before:    encodeRow(before),
+		After:     encodeRow(after),
+		CreatedAt: db.NowFunc(),
+	}
+}
+
+func (p *auditPlugin) write(db *gorm.DB, records []ChangeRecord) {
+	if len(records) == 0 {
+		return
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).Table(p.config.HistoryTable).Create(&records).Error; err != nil {
+		db.AddError(fmt.Errorf("db: writing change history: %w", err))
+	}
+}
+
+func setIfZero(stmt *gorm.Statement, field *schema.Field, value any) {
+	rv := stmt.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			elem := reflect.Indirect(rv.Index(i))
+			if _, zero := field.ValueOf(stmt.Context, elem); zero {
+				_ = field.Set(stmt.Context, elem, value)
+			}
+		}
+	case reflect.Struct:
+		if _, zero := field.ValueOf(stmt.Context, rv); zero {
+			_ = field.Set(stmt.Context, rv, value)
+		}
+	}
+}
+
+func softDeleteField(s *schema.Schema) *schema.Field {
+	for _, field := range s.Fields {
+		if field.FieldType == deletedAtType && field.DBName != "" {
+			return field
+		}
+	}
+	return nil
+}
+
+func primaryKeyCondition(stmt *gorm.Statement, value reflect.Value) (any, []any) {
+	if !value.IsValid() || len(stmt.Schema.PrimaryFields) == 0 {
+		return nil, nil
+	}
+	_, queryValues := schema.GetIdentityFieldValuesMap(stmt.Context, value, stmt.Schema.PrimaryFields)
+	return schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, queryValues)
+}
+
+func primaryKeyValues(s *schema.Schema, row map[string]any) []any {
+	values := make([]any, 0, len(s.PrimaryFieldDBNames))
+	for _, name := range s.PrimaryFieldDBNames {
+		values = append(values, row[name])
+	}
+	return values
+}
+
+func recordID(s *schema.Schema, row map[string]any) string {
+	parts := make([]string, 0, len(s.PrimaryFieldDBNames))
+	for _, value := range primaryKeyValues(s, row) {
+		parts = append(parts, fmt.Sprint(normaliseColumnValue(value)))
+	}
+	return strings.Join(parts, ",")
+}
+
+func diffRows(before, after map[string]any) (map[string]any, map[string]any) {
+	oldValues, newValues := map[string]any{}, map[string]any{}
+	for column, value := range after {
+		previous := normaliseColumnValue(before[column])
+		current := normaliseColumnValue(value)
+		if !reflect.DeepEqual(previous, current) {
+			oldValues[column] = previous
+			newValues[column] = current
+		}
+	}
+	return oldValues, newValues
+}
+
+func encodeRow(row map[string]any) string {
+	if row == nil {
+		return ""
+	}
+	normalised := make(map[string]any, len(row))
+	for column, value := range row {
+		normalised[column] = normaliseColumnValue(value)
+	}
+	encoded, err := json.Marshal(normalised)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+// normaliseColumnValue converts driver values to comparable JSON friendly ones; MySQL returns
+// text columns as []byte when scanning into maps.
+func normaliseColumnValue(value any) any {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case *any:
+		if v == nil {
+			return nil
+		}
+		return normaliseColumnValue(*v)
+	default:
+		return v
+	}
+}
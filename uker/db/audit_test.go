@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type invoice struct {
+	ID     uint
+	Number string
+	Amount int
+	AuditFields
+	DeletedAt gorm.DeletedAt
+}
+
+func openAudited(t *testing.T, config AuditConfig) *gorm.DB {
+	t.Helper()
+	return openTestDB(t, sqliteMemory, []gorm.Plugin{NewAuditPlugin(config)}, &invoice{})
+}
+
+func TestAuditFillsActorColumns(t *testing.T) {
+	conn := openAudited(t, AuditConfig{})
+	alice := WithActor(context.Background(), "alice")
+	bob := WithActor(context.Background(), "bob")
+
+	entity := &invoice{Number: "A-1", Amount: 10}
+	if err := conn.WithContext(alice).Create(entity).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if entity.CreatedBy != "alice" || entity.UpdatedBy != "alice" {
+		t.Fatalf("unexpected create audit fields: %+v", entity.AuditFields)
+	}
+
+	if err := conn.WithContext(bob).Model(entity).Update("amount", 20).Error; err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := conn.WithContext(bob).Model(entity).Select("number").Updates(&invoice{Number: "A-2"}).Error; err != nil {
+		t.Fatalf("Updates: %v", err)
+	}
+
+	var stored invoice
+	if err := conn.First(&stored, entity.ID).Error; err != nil {
+		t.Fatalf("First: %v", err)
+	}
+	if stored.CreatedBy != "alice" || stored.UpdatedBy != "bob" || stored.Number != "A-2" {
+		t.Fatalf("unexpected stored audit fields: %+v", stored)
+	}
+
+	if err := conn.WithContext(bob).Delete(&stored).Error; err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	var deleted invoice
+	if err := conn.Unscoped().First(&deleted, entity.ID).Error; err != nil {
+		t.Fatalf("Unscoped First: %v", err)
+	}
+	if !deleted.DeletedAt.Valid || deleted.DeletedBy != "bob" {
+		t.Fatalf("expected soft delete by bob, got %+v", deleted)
+	}
+}
+
+func TestAuditWritesChangeHistory(t *testing.T) {
+	conn := openAudited(t, AuditConfig{History: true})
+	ctx := WithActor(context.Background(), "alice")
+
+	entity := &invoice{Number: "A-1", Amount: 10}
+	if err := conn.WithContext(ctx).Create(entity).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := conn.WithContext(ctx).Model(entity).Update("amount", 25).Error; err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := conn.WithContext(ctx).Model(&invoice{}).Where("number = ?", "missing").Update("amount", 1).Error; err != nil {
+		t.Fatalf("Update without matches: %v", err)
+	}
+	if err := conn.WithContext(ctx).Delete(entity).Error; err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	var records []ChangeRecord
+	if err := conn.Table(DefaultHistoryTable).Order("id").Find(&records).Error; err != nil {
+		t.Fatalf("Find history: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 history records, got %+v", records)
+	}
+
+	actions := []string{records[0].Action, records[1].Action, records[2].Action}
+	if actions[0] != ActionCreate || actions[1] != ActionUpdate || actions[2] != ActionDelete {
+		t.Fatalf("unexpected actions %v", actions)
+	}
+	for _, record := range records {
+		if record.Table != "invoices" || record.RecordID != "1" || record.Actor != "alice" {
+			t.Fatalf("unexpected record %+v", record)
+		}
+	}
+
+	var before, after map[string]any
+	if err := json.Unmarshal([]byte(records[1].Before), &before); err != nil {
+		t.Fatalf("before: %v", err)
+	}
+	if err := json.Unmarshal([]byte(records[1].After), &after); err != nil {
+		t.Fatalf("after: %v", err)
+	}
+	if before["amount"] != float64(10) || after["amount"] != float64(25) {
+		t.Fatalf("unexpected diff %v -> %v", before, after)
+	}
+	if _, ok := after["number"]; ok {
+		t.Fatalf("unchanged columns should not be part of the diff: %v", after)
+	}
+	if records[0].Before != "" || records[2].After != "" {
+		t.Fatalf("create should have no before and delete no after: %+v", records)
+	}
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff returns an exponential wait for the given attempt, starting at min and capped at
+// max when it is positive, with up to 50% random jitter so parallel instances do not retry
+// in lockstep.
+func Backoff(attempt int, min, max time.Duration) time.Duration {
+	if min <= 0 {
+		return 0
+	}
+
+	sleep := min
+	for i := 0; i < attempt && sleep <= math.MaxInt64/2 && (max <= 0 || sleep < max); i++ {
+		sleep *= 2
+	}
+	if max > 0 && sleep > max {
+		sleep = max
+	}
+
+	jitter := time.Duration(rand.Int64N(int64(sleep)/2 + 1))
+	return sleep/2 + jitter
+}
+
+// sleepContext waits for d or until ctx is done, returning the context error in that case.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/unknowns24/uker/uker/pagination"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultBatchSize is the batch size used by BulkUpsert, ChunkedUpdate and ChunkedDelete when
+// the one given is not positive.
+const DefaultBatchSize = 500
+
+var (
+	errMissingConflictColumns = errors.New("db: missing conflict columns")
+	errTenantUpsert           = errors.New("db: BulkUpsert cannot keep tenant scoped models to their tenant on MySQL")
+)
+
+// BulkUpsert inserts rows in batches of batchSize and, for rows clashing with an existing one
+// on conflictColumns, updates updateColumns instead, or every column but the primary key and
+// created_at when updateColumns is empty. GORM renders it as ON DUPLICATE KEY UPDATE on MySQL,
+// which ignores conflictColumns and uses the table unique keys, and as ON CONFLICT on
+// PostgreSQL and SQLite. It returns the rows affected as reported by the driver; MySQL counts
+// updated rows twice.
+//
+// For TenantScoped models the tenant column is added to conflictColumns and the update only
+// applies when the existing row has the tenant of the new one, so an upsert never overwrites a
+// row of another tenant; the table needs a unique key covering the tenant column. MySQL cannot
+// express either guard, so BulkUpsert fails on TenantScoped models there.
+func BulkUpsert[T any](ctx context.Context, gdb *gorm.DB, rows []T, conflictColumns, updateColumns []string, batchSize int) (int64, error) {
+	if gdb == nil {
+		return 0, errNilDB
+	}
+	if len(conflictColumns) == 0 {
+		return 0, errMissingConflictColumns
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	stmt := &gorm.Statement{DB: gdb}
+	if err := stmt.Parse(new(T)); err != nil {
+		return 0, err
+	}
+	tenant := tenantColumn(stmt.Schema)
+	if tenant != "" && DriverOf(gdb) == DriverMySQL {
+		return 0, errTenantUpsert
+	}
+
+	result := Conn(ctx, gdb).Clauses(upsertClause(conflictColumns, updateColumns, tenant)).CreateInBatches(rows, batchSize)
+	return result.RowsAffected, result.Error
+}
+
+// upsertClause builds the ON CONFLICT clause of BulkUpsert. A non-empty tenant is the tenant
+// column of the model, added to the conflict target and required to match on update.
+func upsertClause(conflictColumns, updateColumns []string, tenant string) clause.OnConflict {
+	columns := make([]clause.Column, 0, len(conflictColumns)+1)
+	scoped := false
+	for _, name := range conflictColumns {
+		columns = append(columns, clause.Column{Name: name})
+		scoped = scoped || name == tenant
+	}
+	if tenant != "" && !scoped {
+		columns = append(columns, clause.Column{Name: tenant})
+	}
+
+	onConflict := clause.OnConflict{Columns: columns}
+	if len(updateColumns) == 0 {
+		onConflict.UpdateAll = true
+	} else {
+		onConflict.DoUpdates = clause.AssignmentColumns(updateColumns)
+	}
+	if tenant != "" {
+		onConflict.Where = clause.Where{Exprs: []clause.Expression{clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: tenant},
+			Value:  clause.Column{Table: "excluded", Name: tenant},
+		}}}
+	}
+	return onConflict
+}
+
+// ChunkedUpdate applies updates, given as for gorm's Updates, to the rows of T matching the
+// scopes. It walks the table by primary key in batches of batchSize with the keyset logic of
+// pagination, so each statement only locks one batch and long backfills never lock the whole
+// table. It returns the number of rows updated.
+func ChunkedUpdate[T any](ctx context.Context, gdb *gorm.DB, batchSize int, updates any, scopes ...Scope) (int64, error) {
+	return walkChunks[T](ctx, gdb, batchSize, scopes, func(batch *gorm.DB) (int64, error) {
+		result := batch.Updates(updates)
+		return result.RowsAffected, result.Error
+	})
+}
+
+// ChunkedDelete deletes, softly when T embeds gorm.DeletedAt, the rows of T matching the
+// scopes in batches of batchSize walked by primary key like ChunkedUpdate. It returns the
+// number of rows deleted.
+func ChunkedDelete[T any](ctx context.Context, gdb *gorm.DB, batchSize int, scopes ...Scope) (int64, error) {
+	return walkChunks[T](ctx, gdb, batchSize, scopes, func(batch *gorm.DB) (int64, error) {
+		result := batch.Delete(new(T))
+		return result.RowsAffected, result.Error
+	})
+}
+
+// walkChunks selects the primary keys of the next batch after the last one processed and runs
+// apply on the rows with those keys, until a batch comes back incomplete. apply returns the
+// number of rows it changed.
+func walkChunks[T any](ctx context.Context, gdb *gorm.DB, batchSize int, scopes []Scope, apply func(*gorm.DB) (int64, error)) (int64, error) {
+	if gdb == nil {
+		return 0, errNilDB
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	stmt := &gorm.Statement{DB: gdb}
+	if err := stmt.Parse(new(T)); err != nil {
+		return 0, err
+	}
+	if len(stmt.Schema.PrimaryFieldDBNames) != 1 {
+		return 0, fmt.Errorf("db: chunked writes need a single column primary key, %s has %d", stmt.Schema.Table, len(stmt.Schema.PrimaryFieldDBNames))
+	}
+	key := stmt.Schema.PrimaryFieldDBNames[0]
+
+	params := pagination.Params{
+		Limit: batchSize,
+		Sort:  []pagination.SortExpression{{Field: key, Direction: pagination.DirectionAsc}},
+	}
+
+	var total int64
+	for {
+		query, err := pagination.Apply(Conn(ctx, gdb).Model(new(T)).Scopes(scopes...), params)
+		if err != nil {
+			return total, err
+		}
+
+		var keys []any
+		if err := query.Pluck(key, &keys).Error; err != nil {
+			return total, err
+		}
+		more := len(keys) > batchSize
+		if more {
+			keys = keys[:batchSize]
+		}
+		if len(keys) == 0 {
+			return total, nil
+		}
+
+		batch := Conn(ctx, gdb).Model(new(T)).Scopes(scopes...).Where(clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: key}, Values: keys})
+		changed, err := apply(batch)
+		total += changed
+		if err != nil {
+			return total, err
+		}
+
+		if !more {
+			return total, nil
+		}
+		params.Cursor = &pagination.CursorPayload{After: map[string]string{key: keyString(keys[len(keys)-1])}}
+	}
+}
+
+func keyString(value any) string {
+	if raw, ok := value.([]byte); ok {
+		return string(raw)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,211 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type item struct {
+	ID        uint
+	SKU       string `gorm:"size:32;uniqueIndex"`
+	Name      string
+	Stock     int
+	Processed bool
+	DeletedAt gorm.DeletedAt
+}
+
+type tenantItem struct {
+	ID       uint
+	TenantID string `gorm:"size:64;uniqueIndex:idx_tenant_item_sku"`
+	SKU      string `gorm:"size:32;uniqueIndex:idx_tenant_item_sku"`
+	Stock    int
+}
+
+func (tenantItem) TenantColumn() string {
+	return "tenant_id"
+}
+
+// sharedItem is tenant scoped but, wrongly, unique across tenants.
+type sharedItem struct {
+	ID       uint
+	TenantID string `gorm:"size:64"`
+	SKU      string `gorm:"size:32;uniqueIndex"`
+	Stock    int
+}
+
+func (sharedItem) TenantColumn() string {
+	return "tenant_id"
+}
+
+func openItems(t *testing.T, count int) *gorm.DB {
+	t.Helper()
+
+	conn := openTestDB(t, sqliteMemory, nil, &item{})
+	if count == 0 {
+		return conn
+	}
+
+	rows := make([]item, 0, count)
+	for i := 1; i <= count; i++ {
+		rows = append(rows, item{SKU: fmt.Sprintf("sku-%03d", i), Name: "item", Stock: i})
+	}
+	if err := conn.CreateInBatches(rows, 100).Error; err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	return conn
+}
+
+func TestBulkUpsertInsertsAndUpdates(t *testing.T) {
+	conn := openItems(t, 0)
+	ctx := context.Background()
+
+	rows := []item{{SKU: "a", Name: "apple", Stock: 1}, {SKU: "b", Name: "banana", Stock: 2}, {SKU: "c", Name: "cherry", Stock: 3}}
+	if _, err := BulkUpsert(ctx, conn, rows, []string{"sku"}, nil, 2); err != nil {
+		t.Fatalf("BulkUpsert: %v", err)
+	}
+
+	changed := []item{{SKU: "a", Name: "avocado", Stock: 10}, {SKU: "d", Name: "date", Stock: 4}}
+	if _, err := BulkUpsert(ctx, conn, changed, []string{"sku"}, []string{"stock"}, 0); err != nil {
+		t.Fatalf("BulkUpsert: %v", err)
+	}
+
+	var stored []item
+	if err := conn.Order("sku").Find(&stored).Error; err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(stored) != 4 {
+		t.Fatalf("expected 4 items, got %+v", stored)
+	}
+	if stored[0].Stock != 10 || stored[0].Name != "apple" {
+		t.Fatalf("expected only the stock of a to change, got %+v", stored[0])
+	}
+
+	if _, err := BulkUpsert(ctx, conn, rows, nil, nil, 0); err == nil {
+		t.Fatalf("expected an error without conflict columns")
+	}
+}
+
+func TestUpsertClausePerDialect(t *testing.T) {
+	dialectors := map[string]gorm.Dialector{
+		"ON DUPLICATE KEY UPDATE `stock`=VALUES(`stock`)":              mysql.New(mysql.Config{DSN: "user:pass@tcp(localhost:3306)/db", SkipInitializeWithVersion: true}),
+		`ON CONFLICT ("sku") DO UPDATE SET "stock"="excluded"."stock"`: postgres.New(postgres.Config{DSN: "host=localhost user=test dbname=test"}),
+	}
+
+	for want, dialector := range dialectors {
+		conn, err := gorm.Open(dialector, &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+
+		stmt := conn.Clauses(upsertClause([]string{"sku"}, []string{"stock"}, "")).Create(&[]item{{SKU: "a", Stock: 1}}).Statement
+		if sql := stmt.SQL.String(); !strings.Contains(sql, want) {
+			t.Fatalf("expected %q in %s", want, sql)
+		}
+	}
+}
+
+func TestChunkedUpdateWalksByPrimaryKey(t *testing.T) {
+	conn := openItems(t, 25)
+
+	batches := 0
+	if err := conn.Callback().Update().Before("gorm:update").Register("test:count_batches", func(*gorm.DB) {
+		batches++
+	}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	updated, err := ChunkedUpdate[item](context.Background(), conn, 10, map[string]any{"processed": true}, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("stock > ?", 3)
+	})
+	if err != nil {
+		t.Fatalf("ChunkedUpdate: %v", err)
+	}
+	if updated != 22 || batches != 3 {
+		t.Fatalf("expected 22 rows in 3 batches, got %d rows in %d", updated, batches)
+	}
+
+	var processed int64
+	if err := conn.Model(&item{}).Where("processed = ?", true).Count(&processed).Error; err != nil || processed != 22 {
+		t.Fatalf("processed = %d (%v)", processed, err)
+	}
+}
+
+func TestChunkedDelete(t *testing.T) {
+	conn := openItems(t, 20)
+
+	deleted, err := ChunkedDelete[item](context.Background(), conn, 5, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("stock % 2 = 0")
+	})
+	if err != nil || deleted != 10 {
+		t.Fatalf("ChunkedDelete = %d (%v)", deleted, err)
+	}
+
+	var remaining int64
+	if err := conn.Model(&item{}).Count(&remaining).Error; err != nil || remaining != 10 {
+		t.Fatalf("remaining = %d (%v)", remaining, err)
+	}
+	if err := conn.Unscoped().Model(&item{}).Count(&remaining).Error; err != nil || remaining != 20 {
+		t.Fatalf("expected soft deletes, got %d rows (%v)", remaining, err)
+	}
+}
+
+func TestBulkUpsertStaysInTenant(t *testing.T) {
+	conn := openTestDB(t, sqliteMemory, []gorm.Plugin{NewTenantPlugin(TenantConfig{})}, &tenantItem{}, &sharedItem{})
+	acme, globex := WithTenant(context.Background(), "acme"), WithTenant(context.Background(), "globex")
+
+	if _, err := BulkUpsert(acme, conn, []tenantItem{{SKU: "a", Stock: 1}}, []string{"sku"}, []string{"stock"}, 0); err != nil {
+		t.Fatalf("BulkUpsert acme: %v", err)
+	}
+	if _, err := BulkUpsert(globex, conn, []tenantItem{{SKU: "a", Stock: 5}}, []string{"sku"}, []string{"stock"}, 0); err != nil {
+		t.Fatalf("BulkUpsert globex: %v", err)
+	}
+	if _, err := BulkUpsert(acme, conn, []tenantItem{{SKU: "a", Stock: 7}}, []string{"sku"}, nil, 0); err != nil {
+		t.Fatalf("BulkUpsert acme again: %v", err)
+	}
+
+	var mine, theirs tenantItem
+	if err := conn.WithContext(acme).Where("sku = ?", "a").First(&mine).Error; err != nil || mine.Stock != 7 {
+		t.Fatalf("acme row = %+v (%v)", mine, err)
+	}
+	if err := conn.WithContext(globex).Where("sku = ?", "a").First(&theirs).Error; err != nil || theirs.Stock != 5 {
+		t.Fatalf("globex row = %+v (%v)", theirs, err)
+	}
+
+	if err := conn.WithContext(acme).Create(&sharedItem{SKU: "a", Stock: 1}).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := BulkUpsert(globex, conn, []sharedItem{{SKU: "a", Stock: 5}}, []string{"sku"}, nil, 0); err == nil {
+		t.Fatalf("expected the upsert to fail instead of taking the row of another tenant")
+	}
+	var shared sharedItem
+	if err := conn.WithContext(acme).First(&shared).Error; err != nil || shared.Stock != 1 || shared.TenantID != "acme" {
+		t.Fatalf("acme shared row = %+v (%v)", shared, err)
+	}
+}
+
+func TestUpsertClauseGuardsTenant(t *testing.T) {
+	conn, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost user=test dbname=test"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	stmt := conn.Clauses(upsertClause([]string{"sku"}, []string{"stock"}, "tenant_id")).Create(&[]tenantItem{{TenantID: "acme", SKU: "a", Stock: 1}}).Statement
+	want := `ON CONFLICT ("sku","tenant_id") DO UPDATE SET "stock"="excluded"."stock" WHERE "tenant_items"."tenant_id" = "excluded"."tenant_id"`
+	if sql := stmt.SQL.String(); !strings.Contains(sql, want) {
+		t.Fatalf("expected %q in %s", want, sql)
+	}
+
+	mysqlConn, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(localhost:3306)/db", SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := BulkUpsert(context.Background(), mysqlConn, []tenantItem{{SKU: "a"}}, []string{"sku"}, nil, 0); err == nil {
+		t.Fatalf("expected BulkUpsert on a tenant scoped model to fail on MySQL")
+	}
+}
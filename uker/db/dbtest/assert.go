@@ -0,0 +1,50 @@
+package dbtest
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// AssertRowCount checks that table holds want rows matching the optional conditions, given
+// as for gorm's Where, e.g. AssertRowCount(t, tx, "users", 1, "email = ?", email).
+func AssertRowCount(t testing.TB, gdb *gorm.DB, table string, want int64, conds ...any) {
+	t.Helper()
+
+	if got := count(t, gdb, table, conds); got != want {
+		t.Errorf("dbtest: %s has %d matching rows, want %d", table, got, want)
+	}
+}
+
+// AssertExists checks that table holds at least one row matching the conditions.
+func AssertExists(t testing.TB, gdb *gorm.DB, table string, conds ...any) {
+	t.Helper()
+
+	if count(t, gdb, table, conds) == 0 {
+		t.Errorf("dbtest: no row of %s matches %v", table, conds)
+	}
+}
+
+// AssertNotExists checks that no row of table matches the conditions.
+func AssertNotExists(t testing.TB, gdb *gorm.DB, table string, conds ...any) {
+	t.Helper()
+
+	if got := count(t, gdb, table, conds); got != 0 {
+		t.Errorf("dbtest: %d rows of %s match %v, want none", got, table, conds)
+	}
+}
+
+func count(t testing.TB, gdb *gorm.DB, table string, conds []any) int64 {
+	t.Helper()
+
+	query := gdb.Table(table)
+	if len(conds) > 0 {
+		query = query.Where(conds[0], conds[1:]...)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		t.Fatalf("dbtest: counting %s: %v", table, err)
+	}
+	return total
+}
@@ -0,0 +1,142 @@
+// Package dbtest provides SQLite backed databases for tests: each test gets its own database,
+// migrated and loaded with fixtures, and works inside a transaction rolled back when it ends.
+//
+//	func TestListMembers(t *testing.T) {
+//		tx := dbtest.New(t, dbtest.WithModels(&Member{}), dbtest.WithFixtures(os.DirFS("testdata"), "members.yml"))
+//		repo := NewMemberRepo(tx)
+//		...
+//		dbtest.AssertRowCount(t, tx, "members", 3)
+//	}
+package dbtest
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/unknowns24/uker/uker/db"
+	"github.com/unknowns24/uker/uker/db/migrate"
+	"github.com/unknowns24/uker/uker/db/sqlite"
+	"gorm.io/gorm"
+)
+
+// Option configures the database opened by Open and New.
+type Option func(*config)
+
+type config struct {
+	tempFile   bool
+	models     []any
+	migrations []migrate.Option
+	fixtures   []fixtureSet
+	connector  []db.Option
+}
+
+type fixtureSet struct {
+	fsys  fs.FS
+	paths []string
+}
+
+// WithModels creates the tables of the given models with GORM AutoMigrate.
+func WithModels(models ...any) Option {
+	return func(c *config) {
+		c.models = append(c.models, models...)
+	}
+}
+
+// WithMigrations applies versioned migrations, e.g. migrate.WithFS(migrationsFS, "migrations").
+func WithMigrations(opts ...migrate.Option) Option {
+	return func(c *config) {
+		c.migrations = append(c.migrations, opts...)
+	}
+}
+
+// WithFixtures loads the YAML or JSON fixture files at paths inside fsys after migrating.
+// See LoadFixtures for the file format.
+func WithFixtures(fsys fs.FS, paths ...string) Option {
+	return func(c *config) {
+		c.fixtures = append(c.fixtures, fixtureSet{fsys: fsys, paths: paths})
+	}
+}
+
+// WithTempFile stores the database in a file inside t.TempDir() instead of memory, e.g. to
+// inspect it after a failure or to use several connections.
+func WithTempFile() Option {
+	return func(c *config) {
+		c.tempFile = true
+	}
+}
+
+// WithConnectorOptions passes options such as db.WithLogger to the connector.
+func WithConnectorOptions(opts ...db.Option) Option {
+	return func(c *config) {
+		c.connector = append(c.connector, opts...)
+	}
+}
+
+// Open returns a new migrated database with its fixtures loaded. It is closed when the test
+// ends. Most tests should use New, which also isolates them in a transaction.
+func Open(t testing.TB, opts ...Option) *gorm.DB {
+	t.Helper()
+
+	var cfg config
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+
+	path := sqlite.Memory
+	if cfg.tempFile {
+		path = filepath.Join(t.TempDir(), "test.db")
+	}
+
+	conn, err := sqlite.New(sqlite.ConnData{Path: path}, cfg.connector...).Open(cfg.models...)
+	if err != nil {
+		t.Fatalf("dbtest: opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close(conn) })
+
+	if len(cfg.migrations) > 0 {
+		migrator, err := migrate.New(conn, cfg.migrations...)
+		if err != nil {
+			t.Fatalf("dbtest: %v", err)
+		}
+		if _, err := migrator.Up(context.Background()); err != nil {
+			t.Fatalf("dbtest: %v", err)
+		}
+	}
+
+	for _, set := range cfg.fixtures {
+		if err := LoadFixtures(conn, set.fsys, set.paths...); err != nil {
+			t.Fatalf("dbtest: %v", err)
+		}
+	}
+
+	return conn
+}
+
+// Tx begins a transaction on gdb that is rolled back when the test ends. The returned handle
+// is bound to a context carrying the transaction, so code using db.Conn or db.WithTx with
+// tx.Statement.Context joins it.
+//
+// An in-memory database has a single connection that the transaction holds: every query of
+// the test must go through the returned handle or its context.
+func Tx(t testing.TB, gdb *gorm.DB) *gorm.DB {
+	t.Helper()
+
+	tx := gdb.Begin()
+	if tx.Error != nil {
+		t.Fatalf("dbtest: beginning transaction: %v", tx.Error)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+
+	joined, _ := db.TxFromContext(db.ContextWithTx(context.Background(), tx))
+	return joined
+}
+
+// New opens a database with Open and returns a transaction on it created with Tx.
+func New(t testing.TB, opts ...Option) *gorm.DB {
+	t.Helper()
+	return Tx(t, Open(t, opts...))
+}
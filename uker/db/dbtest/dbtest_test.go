@@ -0,0 +1,101 @@
+package dbtest
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/unknowns24/uker/uker/db"
+	"github.com/unknowns24/uker/uker/db/migrate"
+	"gorm.io/gorm"
+)
+
+type author struct {
+	ID   uint
+	Name string
+}
+
+type book struct {
+	ID       uint
+	AuthorID uint
+	Title    string
+}
+
+var fixtures = fstest.MapFS{
+	"authors.yml": {Data: []byte(`
+authors:
+  - id: 1
+    name: Ursula
+  - id: 2
+    name: Italo
+books:
+  - author_id: 1
+    title: The Dispossessed
+`)},
+	"books.json": {Data: []byte(`{"books": [{"author_id": 2, "title": "Invisible Cities"}, {"author_id": 2, "title": "If on a winter's night"}]}`)},
+}
+
+func TestNewLoadsFixtures(t *testing.T) {
+	tx := New(t, WithModels(&author{}, &book{}), WithFixtures(fixtures, "authors.yml", "books.json"))
+
+	AssertRowCount(t, tx, "authors", 2)
+	AssertRowCount(t, tx, "books", 2, "author_id = ?", 2)
+	AssertExists(t, tx, "books", "title = ?", "The Dispossessed")
+	AssertNotExists(t, tx, "authors", "name = ?", "Jorge")
+}
+
+func TestTxIsRolledBackAfterTheTest(t *testing.T) {
+	conn := Open(t, WithModels(&author{}), WithTempFile())
+
+	t.Run("writes", func(t *testing.T) {
+		tx := Tx(t, conn)
+		if err := tx.Create(&author{Name: "Ursula"}).Error; err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		AssertRowCount(t, tx, "authors", 1)
+	})
+
+	AssertRowCount(t, conn, "authors", 0)
+}
+
+func TestTxIsJoinedThroughTheContext(t *testing.T) {
+	tx := New(t, WithModels(&author{}))
+	ctx := tx.Statement.Context
+
+	if joined, ok := db.TxFromContext(ctx); !ok || db.Conn(ctx, nil) != joined {
+		t.Fatalf("expected the context to carry the test transaction")
+	}
+
+	err := db.WithTx(ctx, nil, func(inner *gorm.DB) error {
+		return inner.Create(&author{Name: "Italo"}).Error
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	AssertRowCount(t, tx, "authors", 1)
+}
+
+func TestOpenAppliesMigrations(t *testing.T) {
+	migrations := fstest.MapFS{
+		"migrations/0001_create_tags.up.sql":   {Data: []byte("CREATE TABLE tags (id INTEGER PRIMARY KEY, name TEXT NOT NULL);")},
+		"migrations/0001_create_tags.down.sql": {Data: []byte("DROP TABLE tags;")},
+		"tags.yml":                             {Data: []byte("tags:\n  - name: go\n  - name: sql\n")},
+	}
+
+	tx := New(t, WithMigrations(migrate.WithFS(migrations, "migrations")), WithFixtures(migrations, "tags.yml"))
+	AssertRowCount(t, tx, "tags", 2)
+}
+
+func TestLoadFixturesRejectsInvalidFiles(t *testing.T) {
+	conn := Open(t, WithModels(&author{}))
+	files := fstest.MapFS{
+		"authors.csv": {Data: []byte("id,name\n1,Ursula\n")},
+		"list.yml":    {Data: []byte("- id: 1\n")},
+		"unknown.yml": {Data: []byte("publishers:\n  - name: Minotauro\n")},
+	}
+
+	for _, name := range []string{"authors.csv", "list.yml", "unknown.yml", "missing.yml"} {
+		if err := LoadFixtures(conn, files, name); err == nil {
+			t.Fatalf("expected an error loading %s", name)
+		}
+	}
+}
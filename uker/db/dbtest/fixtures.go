@@ -0,0 +1,77 @@
+package dbtest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// LoadFixtures inserts the rows described by YAML (.yml, .yaml) or JSON (.json) files. Each
+// file maps table names to lists of rows, and tables are filled in file order:
+//
+//	business_members:
+//	  - id: mem-1
+//	    business_id: biz-1
+//	    created_at: 2024-01-01T10:00:00Z
+func LoadFixtures(gdb *gorm.DB, fsys fs.FS, paths ...string) error {
+	if gdb == nil {
+		return errors.New("dbtest: nil db")
+	}
+
+	for _, name := range paths {
+		switch strings.ToLower(path.Ext(name)) {
+		case ".yml", ".yaml", ".json":
+		default:
+			return fmt.Errorf("dbtest: unsupported fixture format %q", name)
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return fmt.Errorf("dbtest: reading fixture: %w", err)
+		}
+		if err := loadFixture(gdb, data); err != nil {
+			return fmt.Errorf("dbtest: fixture %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// loadFixture parses data with the YAML decoder, which also accepts JSON, keeping the order
+// of the tables as written.
+func loadFixture(gdb *gorm.DB, data []byte) error {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return err
+	}
+	if len(document.Content) == 0 {
+		return nil
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return errors.New("expected a mapping of table names to rows")
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		table := root.Content[i].Value
+
+		var rows []map[string]any
+		if err := root.Content[i+1].Decode(&rows); err != nil {
+			return fmt.Errorf("table %s: %w", table, err)
+		}
+		for _, row := range rows {
+			if len(row) == 0 {
+				continue
+			}
+			if err := gdb.Table(table).Create(row).Error; err != nil {
+				return fmt.Errorf("table %s: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
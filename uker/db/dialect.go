@@ -0,0 +1,37 @@
+package db
+
+import "gorm.io/gorm"
+
+// Driver identifies the database engine behind a Dialect.
+type Driver string
+
+const (
+	// DriverMySQL identifies MySQL and MariaDB.
+	DriverMySQL Driver = "mysql"
+	// DriverPostgres identifies PostgreSQL.
+	DriverPostgres Driver = "postgres"
+	// DriverSQLite identifies SQLite.
+	DriverSQLite Driver = "sqlite"
+)
+
+// Dialect describes how to reach a database. Each supported driver provides its own
+// connection data struct implementing it: MySQLConnData, postgres.ConnData and
+// sqlite.ConnData.
+type Dialect interface {
+	// Driver returns the database engine.
+	Driver() Driver
+	// DSN builds the driver specific connection string.
+	DSN() (string, error)
+	// Dialector returns the GORM dialector used to open the connection.
+	Dialector() (gorm.Dialector, error)
+	// PoolConfig returns the connection pool settings applied after opening.
+	PoolConfig() PoolConfig
+}
+
+// DriverOf returns the driver of an opened connection, based on the GORM dialector name.
+func DriverOf(db *gorm.DB) Driver {
+	if db == nil || db.Dialector == nil {
+		return ""
+	}
+	return Driver(db.Dialector.Name())
+}
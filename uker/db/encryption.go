@@ -0,0 +1,526 @@
+package db
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// EncryptedSerializer is the name of the GORM serializer encrypting a column with the keyring
+// of the encryption plugin: `gorm:"serializer:uker_encrypted"`.
+const EncryptedSerializer = "uker_encrypted"
+
+const (
+	encryptionPluginName = "uker:encryption"
+	// blindIndexTag names, in the gorm tag of an encrypted field, the companion column filled
+	// with its blind index: `gorm:"serializer:uker_encrypted;blindindex:national_id_index"`.
+	blindIndexTag    = "BLINDINDEX"
+	ciphertextPrefix = "v2:"
+	// legacyPrefix marks values written before ciphertexts were bound to their column. They
+	// are still read, and Reencrypt rewrites them.
+	legacyPrefix = "v1:"
+)
+
+var (
+	// ErrUnknownKey is returned when a ciphertext names a key missing from the keyring.
+	ErrUnknownKey = errors.New("db: unknown encryption key")
+
+	errMissingKeyring       = errors.New("db: no keyring in context, register NewEncryptionPlugin")
+	errMissingBlindIndexKey = errors.New("db: keyring has no blind index key")
+	errMalformedCiphertext  = errors.New("db: malformed ciphertext")
+)
+
+func init() {
+	schema.RegisterSerializer(EncryptedSerializer, encryptedSerializer{})
+}
+
+// KeyringOption configures a Keyring.
+type KeyringOption func(*Keyring)
+
+// WithBlindIndexKey sets the HMAC key of BlindIndex. It is independent of the encryption keys
+// so they can rotate without recomputing the indexes.
+func WithBlindIndexKey(key []byte) KeyringOption {
+	return func(k *Keyring) {
+		k.indexKey = slices.Clone(key)
+	}
+}
+
+// Keyring holds the AES keys of the encrypted columns by id. New values are encrypted with the
+// primary key and the id of the key used is stored with each ciphertext, so values written
+// with older keys remain readable while they are rotated with Reencrypt.
+type Keyring struct {
+	primary  string
+	ciphers  map[string]cipher.AEAD
+	indexKey []byte
+}
+
+// NewKeyring returns a keyring encrypting with keys[primary]. Keys must be 16, 24 or 32 bytes
+// long, for AES-128, AES-192 or AES-256, and their ids must not contain ':'.
+func NewKeyring(primary string, keys map[string][]byte, opts ...KeyringOption) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("%w: primary %q", ErrUnknownKey, primary)
+	}
+
+	keyring := &Keyring{primary: primary, ciphers: make(map[string]cipher.AEAD, len(keys))}
+	for keyID, key := range keys {
+		if keyID == "" || strings.Contains(keyID, ":") {
+			return nil, fmt.Errorf("db: invalid encryption key id %q", keyID)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("db: encryption key %q: %w", keyID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		keyring.ciphers[keyID] = aead
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(keyring)
+		}
+	}
+	return keyring, nil
+}
+
+// Primary returns the id of the key used to encrypt new values.
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// Encrypt seals plaintext with AES-GCM under the primary key and returns
+// "v2:<key id>:<base64 nonce and ciphertext>". additionalData is authenticated but not stored,
+// and must be given again to Decrypt; the encrypted columns pass their column name so a
+// ciphertext copied into another column does not decrypt.
+func (k *Keyring) Encrypt(plaintext, additionalData []byte) (string, error) {
+	aead := k.ciphers[k.primary]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, additionalData)
+	return ciphertextPrefix + k.primary + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt with the key it names and the same additionalData.
+func (k *Keyring) Decrypt(ciphertext string, additionalData []byte) ([]byte, error) {
+	keyID, payload, legacy, err := splitCiphertext(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	if legacy {
+		additionalData = nil
+	}
+	aead, ok := k.ciphers[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, errMalformedCiphertext
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("db: decrypting with key %q: %w", keyID, err)
+	}
+	return plaintext, nil
+}
+
+// BlindIndex returns a deterministic HMAC-SHA256 of value, stored in a companion column to
+// search an encrypted column by equality. Normalise values, e.g. trim and lower-case them,
+// the same way when writing and searching.
+func (k *Keyring) BlindIndex(value string) (string, error) {
+	if len(k.indexKey) == 0 {
+		return "", errMissingBlindIndexKey
+	}
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func splitCiphertext(ciphertext string) (keyID, payload string, legacy bool, err error) {
+	rest, ok := strings.CutPrefix(ciphertext, ciphertextPrefix)
+	if !ok {
+		rest, legacy = strings.CutPrefix(ciphertext, legacyPrefix)
+		if !legacy {
+			return "", "", false, errMalformedCiphertext
+		}
+	}
+	keyID, payload, ok = strings.Cut(rest, ":")
+	if !ok || keyID == "" {
+		return "", "", false, errMalformedCiphertext
+	}
+	return keyID, payload, legacy, nil
+}
+
+type keyringKey struct{}
+
+func keyringFromContext(ctx context.Context) (*Keyring, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	keyring, ok := ctx.Value(keyringKey{}).(*Keyring)
+	return keyring, ok
+}
+
+// encryptedSerializer stores strings and byte slices encrypted, and any other type encrypted
+// as JSON, bound to the column name. NULL is kept as NULL.
+type encryptedSerializer struct{}
+
+func (encryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue any) error {
+	fieldValue := reflect.New(field.FieldType)
+	if dbValue != nil {
+		keyring, ok := keyringFromContext(ctx)
+		if !ok {
+			return errMissingKeyring
+		}
+
+		var ciphertext string
+		switch value := dbValue.(type) {
+		case string:
+			ciphertext = value
+		case []byte:
+			ciphertext = string(value)
+		default:
+			return fmt.Errorf("db: unsupported encrypted value %T in %s", dbValue, field.Name)
+		}
+
+		plaintext, err := keyring.Decrypt(ciphertext, []byte(field.DBName))
+		if err != nil {
+			return fmt.Errorf("%s: %w", field.Name, err)
+		}
+		if err := decodePlaintext(plaintext, fieldValue); err != nil {
+			return fmt.Errorf("db: decoding %s: %w", field.Name, err)
+		}
+	}
+
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+	return nil
+}
+
+func (encryptedSerializer) Value(ctx context.Context, field *schema.Field, _ reflect.Value, fieldValue any) (any, error) {
+	if isNilValue(fieldValue) {
+		return nil, nil
+	}
+	keyring, ok := keyringFromContext(ctx)
+	if !ok {
+		return nil, errMissingKeyring
+	}
+
+	plaintext, err := encodePlaintext(fieldValue)
+	if err != nil {
+		return nil, fmt.Errorf("db: encoding %s: %w", field.Name, err)
+	}
+	return keyring.Encrypt(plaintext, []byte(field.DBName))
+}
+
+// encodePlaintext is the inverse of decodePlaintext. value must not be nil.
+func encodePlaintext(value any) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(value))
+	switch {
+	case rv.Kind() == reflect.String:
+		return []byte(rv.String()), nil
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		return rv.Bytes(), nil
+	default:
+		return json.Marshal(value)
+	}
+}
+
+// decodePlaintext stores plaintext in target, a pointer to the field type.
+func decodePlaintext(plaintext []byte, target reflect.Value) error {
+	elem := target.Elem()
+	if elem.Kind() == reflect.Pointer {
+		elem.Set(reflect.New(elem.Type().Elem()))
+		elem = elem.Elem()
+	}
+
+	switch {
+	case elem.Kind() == reflect.String:
+		elem.SetString(string(plaintext))
+		return nil
+	case elem.Kind() == reflect.Slice && elem.Type().Elem().Kind() == reflect.Uint8:
+		elem.SetBytes(plaintext)
+		return nil
+	default:
+		return json.Unmarshal(plaintext, elem.Addr().Interface())
+	}
+}
+
+func isNilValue(value any) bool {
+	if value == nil {
+		return true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+type encryptionPlugin struct {
+	keyring *Keyring
+}
+
+// NewEncryptionPlugin returns a GORM plugin, registered with gorm.DB.Use, providing keyring to
+// the fields tagged `gorm:"serializer:uker_encrypted"`, which are encrypted with AES-GCM on
+// write and decrypted on read. An encrypted field may name a companion column with
+// `blindindex:<column>`; the plugin fills it with Keyring.BlindIndex of the value so the field
+// can be searched by equality:
+//
+//	NationalID      string `gorm:"serializer:uker_encrypted;blindindex:national_id_index"`
+//	NationalIDIndex string `gorm:"size:64;index"`
+//
+//	index, _ := keyring.BlindIndex(nationalID)
+//	conn.Where("national_id_index = ?", index).First(&customer)
+//
+// Conditions on the encrypted column itself never match, since every encryption uses a new
+// random nonce.
+func NewEncryptionPlugin(keyring *Keyring) gorm.Plugin {
+	return &encryptionPlugin{keyring: keyring}
+}
+
+func (p *encryptionPlugin) Name() string {
+	return encryptionPluginName
+}
+
+func (p *encryptionPlugin) Initialize(db *gorm.DB) error {
+	if p.keyring == nil {
+		return errors.New("db: nil keyring")
+	}
+
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("*").Register(encryptionPluginName+":create", p.withKeyring); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("*").Register(encryptionPluginName+":query", p.withKeyring); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("*").Register(encryptionPluginName+":update", p.withKeyring); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("*").Register(encryptionPluginName+":delete", p.withKeyring); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("*").Register(encryptionPluginName+":row", p.withKeyring); err != nil {
+		return err
+	}
+	if err := callbacks.Raw().Before("*").Register(encryptionPluginName+":raw", p.withKeyring); err != nil {
+		return err
+	}
+
+	if err := callbacks.Create().Before("gorm:create").Register(encryptionPluginName+":create_index", p.fillBlindIndexes(false)); err != nil {
+		return err
+	}
+	return callbacks.Update().Before("gorm:update").Register(encryptionPluginName+":update_index", p.fillBlindIndexes(true))
+}
+
+// withKeyring makes the keyring available to the serializer, which only receives the context.
+func (p *encryptionPlugin) withKeyring(db *gorm.DB) {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db.Statement.Context = context.WithValue(ctx, keyringKey{}, p.keyring)
+}
+
+// fillBlindIndexes sets the blind index of the encrypted fields the statement writes. Updates
+// with a struct skip its zero fields unless selected, so update tells to leave their index
+// alone as well.
+func (p *encryptionPlugin) fillBlindIndexes(update bool) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		stmt := db.Statement
+		if db.Error != nil || stmt.Schema == nil {
+			return
+		}
+
+		for _, field := range stmt.Schema.Fields {
+			column, ok := field.TagSettings[blindIndexTag]
+			if !ok {
+				continue
+			}
+			companion := stmt.Schema.LookUpField(column)
+			if companion == nil {
+				db.AddError(fmt.Errorf("db: blind index column %q not found in %s", column, stmt.Schema.Name))
+				return
+			}
+
+			if err := p.fillBlindIndex(stmt, field, companion, update); err != nil {
+				db.AddError(err)
+				return
+			}
+		}
+	}
+}
+
+func (p *encryptionPlugin) fillBlindIndex(stmt *gorm.Statement, field, companion *schema.Field, update bool) error {
+	if values, ok := stmt.Dest.(map[string]any); ok {
+		value, found := values[field.DBName]
+		if !found {
+			value, found = values[field.Name]
+		}
+		if !found {
+			return nil
+		}
+		index, err := p.blindIndexOf(value)
+		if err != nil {
+			return err
+		}
+		stmt.SetColumn(companion.DBName, index, true)
+		return nil
+	}
+
+	selected := slices.Contains(stmt.Selects, "*")
+	if len(stmt.Selects) > 0 && !selected {
+		if !slices.Contains(stmt.Selects, field.DBName) && !slices.Contains(stmt.Selects, field.Name) {
+			return nil
+		}
+		selected = true
+		if !slices.Contains(stmt.Selects, companion.DBName) {
+			stmt.Selects = append(stmt.Selects, companion.DBName)
+		}
+	}
+	if slices.Contains(stmt.Omits, field.DBName) || slices.Contains(stmt.Omits, field.Name) {
+		return nil
+	}
+
+	rows := []reflect.Value{stmt.ReflectValue}
+	if kind := stmt.ReflectValue.Kind(); kind == reflect.Slice || kind == reflect.Array {
+		rows = rows[:0]
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			rows = append(rows, reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	}
+	for _, row := range rows {
+		if row.Kind() != reflect.Struct {
+			continue
+		}
+		// ValueOf of a serializer field returns the serializer, so the raw value is read.
+		value := field.ReflectValueOf(stmt.Context, row)
+		if update && value.IsZero() && !selected {
+			continue
+		}
+		index, err := p.blindIndexOf(value.Interface())
+		if err != nil {
+			return err
+		}
+		if err := companion.Set(stmt.Context, row, index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *encryptionPlugin) blindIndexOf(value any) (string, error) {
+	if isNilValue(value) {
+		return "", nil
+	}
+	plaintext, err := encodePlaintext(value)
+	if err != nil {
+		return "", err
+	}
+	return p.keyring.BlindIndex(string(plaintext))
+}
+
+// Reencrypt rewrites, in batches walked by primary key, the encrypted fields of the rows of T
+// still encrypted with a key other than the primary one, or in the format not bound to their
+// column, along with their blind indexes. Run it after making a new key primary; the old key
+// can be dropped from the keyring once it returns. It returns the number of rows rewritten.
+//
+// It reads from the primary, and each batch is locked and rewritten in its own transaction, so
+// rows written concurrently by the application are neither missed nor overwritten with the
+// values read before the write.
+func Reencrypt[T any](ctx context.Context, gdb *gorm.DB, batchSize int) (int64, error) {
+	if gdb == nil {
+		return 0, errNilDB
+	}
+	keyring, ok := encryptionKeyring(gdb)
+	if !ok {
+		return 0, errMissingKeyring
+	}
+
+	stmt := &gorm.Statement{DB: gdb}
+	if err := stmt.Parse(new(T)); err != nil {
+		return 0, err
+	}
+
+	var columns []string
+	var stale []clause.Expression
+	// The prefix is compared exactly, not with LIKE, where '_' or '%' in a key id would act as
+	// wildcards and hide rows encrypted with another key.
+	current := ciphertextPrefix + keyring.Primary() + ":"
+	for _, field := range stmt.Schema.Fields {
+		if !strings.EqualFold(field.TagSettings["SERIALIZER"], EncryptedSerializer) || field.DBName == "" {
+			continue
+		}
+		columns = append(columns, field.DBName)
+		if companion, ok := field.TagSettings[blindIndexTag]; ok {
+			columns = append(columns, companion)
+		}
+		column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+		stale = append(stale, clause.And(
+			clause.Neq{Column: column, Value: nil},
+			clause.Expr{SQL: "SUBSTR(?, 1, ?) <> ?", Vars: []any{column, utf8.RuneCountInString(current), current}},
+		))
+	}
+	if len(stale) == 0 {
+		return 0, fmt.Errorf("db: %s has no encrypted fields", stmt.Schema.Name)
+	}
+
+	scope := func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(clause.Or(stale...))
+	}
+	return walkChunks[T](UsePrimary(ctx), gdb, batchSize, []Scope{scope}, func(batch *gorm.DB) (int64, error) {
+		var rewritten int64
+		err := batch.Transaction(func(tx *gorm.DB) error {
+			query := tx
+			if DriverOf(tx) != DriverSQLite {
+				query = query.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate})
+			}
+			var rows []T
+			if err := query.Find(&rows).Error; err != nil {
+				return err
+			}
+
+			for i := range rows {
+				result := tx.Session(&gorm.Session{NewDB: true}).Model(&rows[i]).Select(columns).Updates(&rows[i])
+				if result.Error != nil {
+					return result.Error
+				}
+				rewritten += result.RowsAffected
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		return rewritten, nil
+	})
+}
+
+func encryptionKeyring(gdb *gorm.DB) (*Keyring, bool) {
+	plugin, ok := gdb.Config.Plugins[encryptionPluginName].(*encryptionPlugin)
+	if !ok {
+		return nil, false
+	}
+	return plugin.keyring, true
+}
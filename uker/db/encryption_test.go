@@ -0,0 +1,287 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type customer struct {
+	ID              uint
+	Name            string
+	NationalID      string            `gorm:"serializer:uker_encrypted;blindindex:national_id_index"`
+	NationalIDIndex string            `gorm:"size:64;index"`
+	Token           []byte            `gorm:"serializer:uker_encrypted"`
+	Preferences     map[string]string `gorm:"serializer:uker_encrypted"`
+	Note            *string           `gorm:"serializer:uker_encrypted"`
+}
+
+var (
+	oldKey   = bytes.Repeat([]byte{1}, 32)
+	newKey   = bytes.Repeat([]byte{2}, 32)
+	indexKey = []byte("blind-index-key")
+)
+
+func newTestKeyring(t *testing.T, primary string) *Keyring {
+	t.Helper()
+
+	keyring, err := NewKeyring(primary, map[string][]byte{"k1": oldKey, "k2": newKey}, WithBlindIndexKey(indexKey))
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return keyring
+}
+
+func openCustomers(t *testing.T, path string, keyring *Keyring) *gorm.DB {
+	t.Helper()
+	return openTestDB(t, path, []gorm.Plugin{NewEncryptionPlugin(keyring)}, &customer{})
+}
+
+func rawColumn(t *testing.T, conn *gorm.DB, column string, id uint) string {
+	t.Helper()
+
+	var value string
+	if err := conn.Raw("SELECT "+column+" FROM customers WHERE id = ?", id).Row().Scan(&value); err != nil {
+		t.Fatalf("raw %s: %v", column, err)
+	}
+	return value
+}
+
+func TestEncryptedColumnsRoundTrip(t *testing.T) {
+	keyring := newTestKeyring(t, "k1")
+	conn := openCustomers(t, sqliteMemory, keyring)
+
+	entity := &customer{Name: "Ada", NationalID: "30123456", Token: []byte{0, 1, 2}, Preferences: map[string]string{"lang": "es"}}
+	if err := conn.Create(entity).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	raw := rawColumn(t, conn, "national_id", entity.ID)
+	if !strings.HasPrefix(raw, "v2:k1:") || strings.Contains(raw, "30123456") {
+		t.Fatalf("expected the national id to be encrypted with k1, got %q", raw)
+	}
+	if err := conn.Create(&customer{Name: "Bob", NationalID: "30123456"}).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if second := rawColumn(t, conn, "national_id", entity.ID+1); second == raw {
+		t.Fatalf("equal values should not produce equal ciphertexts")
+	}
+
+	var stored customer
+	if err := conn.First(&stored, entity.ID).Error; err != nil {
+		t.Fatalf("First: %v", err)
+	}
+	if stored.NationalID != "30123456" || !bytes.Equal(stored.Token, []byte{0, 1, 2}) || stored.Preferences["lang"] != "es" || stored.Note != nil {
+		t.Fatalf("unexpected decrypted customer %+v", stored)
+	}
+
+	index, err := keyring.BlindIndex("30123456")
+	if err != nil {
+		t.Fatalf("BlindIndex: %v", err)
+	}
+	var matches []customer
+	if err := conn.Where("national_id_index = ?", index).Order("id").Find(&matches).Error; err != nil {
+		t.Fatalf("Find by blind index: %v", err)
+	}
+	if len(matches) != 2 || matches[0].Name != "Ada" {
+		t.Fatalf("expected both customers by blind index, got %+v", matches)
+	}
+
+	if err := conn.Model(&stored).Updates(map[string]any{"national_id": "40999888"}).Error; err != nil {
+		t.Fatalf("Updates: %v", err)
+	}
+	updatedIndex, _ := keyring.BlindIndex("40999888")
+	if got := rawColumn(t, conn, "national_id_index", entity.ID); got != updatedIndex {
+		t.Fatalf("expected the blind index to follow updates, got %q", got)
+	}
+}
+
+func TestEncryptedColumnRejectsTampering(t *testing.T) {
+	conn := openCustomers(t, sqliteMemory, newTestKeyring(t, "k1"))
+
+	entity := &customer{Name: "Ada", NationalID: "30123456"}
+	if err := conn.Create(entity).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	raw := rawColumn(t, conn, "national_id", entity.ID)
+	tampered := raw[:len(raw)-2] + "AA"
+	if tampered == raw {
+		tampered = raw[:len(raw)-2] + "BB"
+	}
+	if err := conn.Exec("UPDATE customers SET national_id = ? WHERE id = ?", tampered, entity.ID).Error; err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+
+	if err := conn.First(&customer{}, entity.ID).Error; err == nil {
+		t.Fatalf("expected tampered ciphertext to fail")
+	}
+
+	unknown, err := NewKeyring("k9", map[string][]byte{"k9": newKey})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	if _, err := unknown.Decrypt(raw, []byte("national_id")); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestReencryptRotatesKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "customers.db")
+	conn := openCustomers(t, path, newTestKeyring(t, "k1"))
+
+	for _, id := range []string{"1", "2", "3"} {
+		if err := conn.Create(&customer{Name: "c" + id, NationalID: id}).Error; err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	if err := conn.Omit("national_id").Create(&customer{Name: "no national id"}).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	rotated := openCustomers(t, path, newTestKeyring(t, "k2"))
+	rewritten, err := Reencrypt[customer](context.Background(), rotated, 2)
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+	if rewritten != 3 {
+		t.Fatalf("expected the 3 rows with encrypted values to be rewritten, got %d", rewritten)
+	}
+	if raw := rawColumn(t, rotated, "national_id", 2); !strings.HasPrefix(raw, "v2:k2:") {
+		t.Fatalf("expected rows encrypted with k2, got %q", raw)
+	}
+
+	again, err := Reencrypt[customer](context.Background(), rotated, 2)
+	if err != nil || again != 0 {
+		t.Fatalf("a second run should find nothing to rewrite: %d (%v)", again, err)
+	}
+
+	onlyNew, err := NewKeyring("k2", map[string][]byte{"k2": newKey}, WithBlindIndexKey(indexKey))
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	var stored customer
+	if err := openCustomers(t, path, onlyNew).First(&stored, 2).Error; err != nil || stored.NationalID != "2" {
+		t.Fatalf("expected the old key to be no longer needed, got %+v (%v)", stored, err)
+	}
+}
+
+func TestReencryptWithWildcardsInKeyIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "customers.db")
+	keys := map[string][]byte{"keyX2024": oldKey, "key_2024": newKey}
+
+	old, err := NewKeyring("keyX2024", keys, WithBlindIndexKey(indexKey))
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	conn := openCustomers(t, path, old)
+	if err := conn.Create(&customer{Name: "Ada", NationalID: "30123456"}).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	primary, err := NewKeyring("key_2024", keys, WithBlindIndexKey(indexKey))
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	rewritten, err := Reencrypt[customer](context.Background(), openCustomers(t, path, primary), 10)
+	if err != nil || rewritten != 1 {
+		t.Fatalf("expected the row encrypted with keyX2024 to be rewritten, got %d (%v)", rewritten, err)
+	}
+	if raw := rawColumn(t, conn, "national_id", 1); !strings.HasPrefix(raw, "v2:key_2024:") {
+		t.Fatalf("expected the row encrypted with key_2024, got %q", raw)
+	}
+}
+
+func TestEncryptedColumnsAreBoundToTheirColumn(t *testing.T) {
+	keyring := newTestKeyring(t, "k1")
+	conn := openCustomers(t, sqliteMemory, keyring)
+
+	entity := &customer{Name: "Ada", NationalID: "30123456"}
+	if err := conn.Create(entity).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := conn.Exec("UPDATE customers SET note = national_id WHERE id = ?", entity.ID).Error; err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if err := conn.First(&customer{}, entity.ID).Error; err == nil {
+		t.Fatalf("expected a ciphertext copied into another column to fail")
+	}
+
+	// Values written before the binding are still read, and Reencrypt upgrades them.
+	aead := keyring.ciphers["k1"]
+	nonce := make([]byte, aead.NonceSize())
+	legacy := legacyPrefix + "k1:" + base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte("40999888"), nil))
+	if err := conn.Exec("UPDATE customers SET national_id = ?, note = NULL WHERE id = ?", legacy, entity.ID).Error; err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	var stored customer
+	if err := conn.First(&stored, entity.ID).Error; err != nil || stored.NationalID != "40999888" {
+		t.Fatalf("expected the legacy value to be readable, got %+v (%v)", stored, err)
+	}
+	if rewritten, err := Reencrypt[customer](context.Background(), conn, 10); err != nil || rewritten != 1 {
+		t.Fatalf("expected the legacy value to be rewritten, got %d (%v)", rewritten, err)
+	}
+	if raw := rawColumn(t, conn, "national_id", entity.ID); !strings.HasPrefix(raw, "v2:k1:") {
+		t.Fatalf("expected the value bound to its column, got %q", raw)
+	}
+}
+
+func TestPartialUpdateKeepsBlindIndex(t *testing.T) {
+	keyring := newTestKeyring(t, "k1")
+	conn := openCustomers(t, sqliteMemory, keyring)
+
+	entity := &customer{Name: "Ada", NationalID: "30123456"}
+	if err := conn.Create(entity).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	index, _ := keyring.BlindIndex("30123456")
+
+	if err := conn.Model(&customer{ID: entity.ID}).Updates(customer{Name: "Ada Lovelace"}).Error; err != nil {
+		t.Fatalf("Updates: %v", err)
+	}
+	if got := rawColumn(t, conn, "national_id_index", entity.ID); got != index {
+		t.Fatalf("an update skipping the national id should keep its blind index, got %q", got)
+	}
+
+	entity.NationalID = ""
+	if err := conn.Model(entity).Select("national_id").Updates(entity).Error; err != nil {
+		t.Fatalf("Updates: %v", err)
+	}
+	empty, _ := keyring.BlindIndex("")
+	if got := rawColumn(t, conn, "national_id_index", entity.ID); got != empty {
+		t.Fatalf("a selected zero national id should update its blind index, got %q", got)
+	}
+}
+
+func TestReencryptReadsThePrimary(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "customers.db")
+	conn := openCustomers(t, path, newTestKeyring(t, "k1"))
+	if err := conn.Create(&customer{Name: "Ada", NationalID: "30123456"}).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	lagging := sqliteDialect{Path: filepath.Join(dir, "replica.db")}
+	replica := openCustomers(t, lagging.Path, newTestKeyring(t, "k1"))
+	if err := Close(replica); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rotated, err := newSQLite(sqliteDialect{Path: path}, WithReplicas(ReplicaRoundRobin, lagging)).Open()
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer Close(rotated)
+	if err := rotated.Use(NewEncryptionPlugin(newTestKeyring(t, "k2"))); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+
+	if rewritten, err := Reencrypt[customer](context.Background(), rotated, 10); err != nil || rewritten != 1 {
+		t.Fatalf("expected the row missing from the replica to be rewritten, got %d (%v)", rewritten, err)
+	}
+}
@@ -1,44 +1,133 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
-	mysqlDriver "gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 )
 
-// MySQLConnData holds the connection data used to connect with MySQL.
-type MySQLConnData struct {
-	Host     string
-	Port     string
-	Database string
-	User     string
-	Password string
+var errNilDialect = errors.New("db: nil dialect")
+
+// Option configures a Connector.
+type Option func(*Connector)
+
+// WithRetry retries the initial connection with capped exponential backoff.
+func WithRetry(retry RetryConfig) Option {
+	return func(c *Connector) {
+		c.retry = retry
+	}
 }
 
-// Connector opens a MySQL connection using the provided configuration.
+// Connector opens a database connection using the provided dialect.
 type Connector struct {
-	conn MySQLConnData
+	dialect  Dialect
+	retry    RetryConfig
+	replicas []Dialect
+	policy   ReplicaPolicy
+	logger   gormlogger.Interface
 }
 
-// NewMySQL creates a new Connector instance.
-func NewMySQL(conn MySQLConnData) Connector {
-	return Connector{conn: conn}
+// New creates a Connector for any Dialect. PostgreSQL and SQLite are provided by the
+// postgres and sqlite subpackages so their drivers are only linked where used.
+func New(dialect Dialect, opts ...Option) Connector {
+	connector := Connector{dialect: dialect}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&connector)
+		}
+	}
+	return connector
 }
 
-// Open establishes a connection to MySQL and optionally runs migrations.
+// NewMySQL creates a new Connector instance for MySQL.
+func NewMySQL(conn MySQLConnData, opts ...Option) Connector {
+	return New(conn, opts...)
+}
+
+// Open establishes a connection and optionally runs migrations.
 func (c Connector) Open(migrate ...any) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local", c.conn.User, c.conn.Password, c.conn.Host, c.conn.Port, c.conn.Database)
-	db, err := gorm.Open(mysqlDriver.Open(dsn), &gorm.Config{})
+	return c.OpenContext(context.Background(), migrate...)
+}
+
+// OpenContext behaves like Open but bounds the startup retries configured with WithRetry by
+// the context deadline.
+func (c Connector) OpenContext(ctx context.Context, migrate ...any) (*gorm.DB, error) {
+	if c.dialect == nil {
+		return nil, errNilDialect
+	}
+
+	db, err := c.connect(ctx, c.dialect)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(migrate) > 0 {
-		if err := db.AutoMigrate(migrate...); err != nil {
+		if err := db.WithContext(ctx).AutoMigrate(migrate...); err != nil {
 			return nil, err
 		}
 	}
 
+	if len(c.replicas) > 0 {
+		if err := c.openReplicas(ctx, db); err != nil {
+			_ = Close(db)
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+func (c Connector) connect(ctx context.Context, dialect Dialect) (*gorm.DB, error) {
+	attempts := c.retry.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := sleepContext(ctx, Backoff(attempt-1, c.retry.InitialBackoff, c.retry.MaxBackoff)); err != nil {
+				return nil, fmt.Errorf("db: giving up after %d attempts: %w", attempt, errors.Join(lastErr, err))
+			}
+		}
+
+		db, err := c.open(ctx, dialect)
+		if err == nil {
+			return db, nil
+		}
+		lastErr = err
+	}
+
+	if attempts > 1 {
+		return nil, fmt.Errorf("db: giving up after %d attempts: %w", attempts, lastErr)
+	}
+	return nil, lastErr
+}
+
+func (c Connector) open(ctx context.Context, dialect Dialect) (*gorm.DB, error) {
+	dialector, err := dialect.Dialector()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{DisableAutomaticPing: true, Logger: c.logger})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	dialect.PoolConfig().apply(sqlDB)
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+
 	return db, nil
 }
@@ -0,0 +1,418 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/unknowns24/uker/uker/id"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultLockTable stores the leases of Lock on databases without native named locks.
+const DefaultLockTable = "uker_locks"
+
+const (
+	lockPollMin = 50 * time.Millisecond
+	lockPollMax = time.Second
+)
+
+var (
+	// ErrLockHeld is returned by TryLock when another holder owns the lock.
+	ErrLockHeld = errors.New("db: lock held by another owner")
+	// ErrLockLost is returned by Refresh when the lease expired or the lock was taken away.
+	ErrLockLost = errors.New("db: lock lost")
+
+	errInvalidLock = errors.New("db: lock needs a name and a positive ttl")
+)
+
+// Lease is a named lock held across processes. It must be renewed with Refresh before its ttl
+// elapses; otherwise it is released and Lost is closed so the work it guards can stop.
+type Lease struct {
+	name    string
+	ttl     time.Duration
+	backend lockBackend
+
+	mu       sync.Mutex
+	expires  time.Time
+	released bool
+
+	lost     chan struct{}
+	lostOnce sync.Once
+	stop     chan struct{}
+	stopOnce sync.Once
+	watching sync.WaitGroup
+}
+
+// lockBackend implements a named lock on one kind of database.
+type lockBackend interface {
+	acquire(ctx context.Context) (bool, error)
+	// refresh extends the lock by ttl and reports whether it is still held.
+	refresh(ctx context.Context, ttl time.Duration) (bool, error)
+	// held reports whether the lock is still held without extending it.
+	held(ctx context.Context) (bool, error)
+	release(ctx context.Context) error
+}
+
+// Lock acquires the lock called name, waiting with backoff while another process holds it,
+// until ctx is done. It uses GET_LOCK on MySQL, advisory locks on PostgreSQL and a lease row
+// in DefaultLockTable otherwise, e.g. on SQLite. The lock is independent of any transaction
+// carried by ctx.
+func Lock(ctx context.Context, gdb *gorm.DB, name string, ttl time.Duration) (*Lease, error) {
+	for attempt := 0; ; attempt++ {
+		lease, err := TryLock(ctx, gdb, name, ttl)
+		if !errors.Is(err, ErrLockHeld) {
+			return lease, err
+		}
+		if err := sleepContext(ctx, Backoff(attempt, lockPollMin, lockPollMax)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// TryLock acquires the lock called name like Lock but fails with ErrLockHeld instead of
+// waiting, e.g. so only one replica runs a scheduled job.
+func TryLock(ctx context.Context, gdb *gorm.DB, name string, ttl time.Duration) (*Lease, error) {
+	if gdb == nil {
+		return nil, errNilDB
+	}
+	if name == "" || ttl <= 0 {
+		return nil, errInvalidLock
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	backend, err := newLockBackend(ctx, gdb, name, ttl)
+	if err != nil {
+		return nil, err
+	}
+	acquired, err := backend.acquire(ctx)
+	if err != nil || !acquired {
+		_ = backend.release(context.WithoutCancel(ctx))
+		if err == nil {
+			err = ErrLockHeld
+		}
+		return nil, err
+	}
+
+	return newLease(name, ttl, backend), nil
+}
+
+// newLease returns the handle of a lock just acquired through backend and starts watching it.
+func newLease(name string, ttl time.Duration, backend lockBackend) *Lease {
+	lease := &Lease{
+		name:    name,
+		ttl:     ttl,
+		backend: backend,
+		expires: time.Now().Add(ttl),
+		lost:    make(chan struct{}),
+		stop:    make(chan struct{}),
+	}
+	lease.watching.Add(1)
+	go lease.watch()
+	return lease
+}
+
+// Name returns the name of the lock.
+func (l *Lease) Name() string {
+	return l.name
+}
+
+// Lost returns a channel closed when the lock is lost: its ttl elapsed without Refresh, the
+// database connection holding it dropped or its lease was taken over. It is not closed by
+// Unlock.
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Refresh extends the lock by its ttl. It returns ErrLockLost, and closes Lost, when the lock
+// is no longer held. The call gives up once the lock expires and runs without holding the
+// lease, so a slow database does not block Unlock.
+func (l *Lease) Refresh(ctx context.Context) error {
+	l.mu.Lock()
+	if l.released || time.Now().After(l.expires) {
+		l.lose()
+		return ErrLockLost
+	}
+	expires := l.expires
+	l.mu.Unlock()
+
+	ctx, cancel := context.WithDeadline(ctx, expires)
+	defer cancel()
+	held, err := l.backend.refresh(ctx, l.ttl)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	if l.released {
+		l.mu.Unlock()
+		return ErrLockLost
+	}
+	if !held {
+		l.lose()
+		return ErrLockLost
+	}
+	l.expires = time.Now().Add(l.ttl)
+	l.mu.Unlock()
+	return nil
+}
+
+// Unlock releases the lock. Calling it again, or after the lock was lost, is a no-op.
+func (l *Lease) Unlock(ctx context.Context) error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	l.watching.Wait()
+
+	l.mu.Lock()
+	released := l.released
+	l.released = true
+	l.mu.Unlock()
+
+	if released {
+		return nil
+	}
+	return l.backend.release(ctx)
+}
+
+// watch releases the lock and closes Lost when it expires or stops being held. It checks the
+// lock a few times per ttl so losses are noticed well before another process could take over.
+// The check runs without l.mu and is bounded by the expiry, so a stalled connection neither
+// blocks Refresh and Unlock nor delays Lost past the ttl.
+func (l *Lease) watch() {
+	defer l.watching.Done()
+
+	ticker := time.NewTicker(max(l.ttl/3, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		l.mu.Lock()
+		released, expires := l.released, l.expires
+		l.mu.Unlock()
+		if released {
+			return
+		}
+
+		taken := false
+		if time.Now().Before(expires) {
+			ctx, cancel := context.WithDeadline(context.Background(), expires)
+			held, err := l.backend.held(ctx)
+			cancel()
+			taken = err == nil && !held
+		}
+
+		l.mu.Lock()
+		if taken || time.Now().After(l.expires) {
+			l.lose()
+			return
+		}
+		l.mu.Unlock()
+	}
+}
+
+// lose closes Lost and releases the lock. It must be called with l.mu held, which it unlocks
+// before releasing the lock with a call bounded by the ttl.
+func (l *Lease) lose() {
+	l.lostOnce.Do(func() { close(l.lost) })
+	release := !l.released
+	l.released = true
+	l.mu.Unlock()
+
+	if release {
+		ctx, cancel := context.WithTimeout(context.Background(), l.ttl)
+		defer cancel()
+		_ = l.backend.release(ctx)
+	}
+}
+
+func newLockBackend(ctx context.Context, gdb *gorm.DB, name string, ttl time.Duration) (lockBackend, error) {
+	driver := DriverOf(gdb)
+	if driver != DriverMySQL && driver != DriverPostgres {
+		owner, err := id.New()
+		if err != nil {
+			return nil, err
+		}
+		lease := &leaseLock{db: gdb.Session(&gorm.Session{NewDB: true}), name: name, owner: owner, ttl: ttl}
+		return lease, lease.migrate(ctx)
+	}
+
+	// Session locks belong to a connection, so one is taken from the pool for the lifetime
+	// of the lock.
+	sqlDB, err := gdb.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if driver == DriverMySQL {
+		var database sql.NullString
+		if err := conn.QueryRowContext(ctx, "SELECT DATABASE()").Scan(&database); err != nil {
+			return nil, errors.Join(err, conn.Close())
+		}
+		return &mysqlLock{conn: conn, name: mysqlLockName(database.String, name)}, nil
+	}
+	return &postgresLock{conn: conn, key: advisoryKey(name)}, nil
+}
+
+// mysqlLock uses GET_LOCK, held by the connection until RELEASE_LOCK or disconnection.
+type mysqlLock struct {
+	conn *sql.Conn
+	name string
+}
+
+// mysqlLockName derives the GET_LOCK name of a lock. Named locks are server wide and limited
+// to 64 characters, so the name is namespaced with the database and hashed to a fixed length.
+func mysqlLockName(database, name string) string {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(database + "." + name))
+	return fmt.Sprintf("uker.lock.%016x", hash.Sum64())
+}
+
+func (m *mysqlLock) acquire(ctx context.Context) (bool, error) {
+	var acquired sql.NullInt64
+	if err := m.conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", m.name).Scan(&acquired); err != nil {
+		return false, fmt.Errorf("db: acquiring lock %s: %w", m.name, err)
+	}
+	return acquired.Valid && acquired.Int64 == 1, nil
+}
+
+func (m *mysqlLock) refresh(ctx context.Context, _ time.Duration) (bool, error) {
+	return m.held(ctx)
+}
+
+func (m *mysqlLock) held(ctx context.Context) (bool, error) {
+	var held sql.NullBool
+	if err := m.conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?) = CONNECTION_ID()", m.name).Scan(&held); err != nil {
+		return false, connectionLost(ctx)
+	}
+	return held.Valid && held.Bool, nil
+}
+
+func (m *mysqlLock) release(ctx context.Context) error {
+	_, err := m.conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", m.name)
+	return errors.Join(err, m.conn.Close())
+}
+
+// postgresLock uses a session advisory lock, held until unlocked or disconnection.
+type postgresLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+func (p *postgresLock) acquire(ctx context.Context) (bool, error) {
+	var acquired bool
+	if err := p.conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", p.key).Scan(&acquired); err != nil {
+		return false, fmt.Errorf("db: acquiring lock: %w", err)
+	}
+	return acquired, nil
+}
+
+func (p *postgresLock) refresh(ctx context.Context, _ time.Duration) (bool, error) {
+	return p.held(ctx)
+}
+
+func (p *postgresLock) held(ctx context.Context) (bool, error) {
+	// pg_locks shows a bigint advisory key split in its high (classid) and low (objid) halves.
+	var held bool
+	err := p.conn.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM pg_locks WHERE locktype = 'advisory' AND pid = pg_backend_pid() AND classid = $1 AND objid = $2 AND objsubid = 1 AND granted)",
+		uint32(uint64(p.key)>>32), uint32(uint64(p.key)),
+	).Scan(&held)
+	if err != nil {
+		return false, connectionLost(ctx)
+	}
+	return held, nil
+}
+
+func (p *postgresLock) release(ctx context.Context) error {
+	_, err := p.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", p.key)
+	return errors.Join(err, p.conn.Close())
+}
+
+// connectionLost handles a failed check of a session lock: unless ctx was cancelled, the
+// connection holding the lock is broken and the server has already dropped it.
+func connectionLost(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func advisoryKey(name string) int64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte("uker.lock." + name))
+	return int64(hash.Sum64())
+}
+
+// lockLease is a row of DefaultLockTable. The lock is free once ExpiresAt has passed.
+type lockLease struct {
+	Name      string    `gorm:"column:name;primaryKey;size:191"`
+	Owner     string    `gorm:"column:owner;size:64"`
+	ExpiresAt time.Time `gorm:"column:expires_at"`
+}
+
+// leaseLock emulates a named lock with an expiring row, taken over once it expires.
+type leaseLock struct {
+	db    *gorm.DB
+	name  string
+	owner string
+	ttl   time.Duration
+}
+
+// table returns a statement on the lease table. Leases are always read and written on the
+// primary: a lagging replica would report a lock as free or still held.
+func (l *leaseLock) table(ctx context.Context) *gorm.DB {
+	return l.db.WithContext(UsePrimary(ctx)).Table(DefaultLockTable)
+}
+
+func (l *leaseLock) migrate(ctx context.Context) error {
+	return l.table(ctx).AutoMigrate(&lockLease{})
+}
+
+func (l *leaseLock) acquire(ctx context.Context) (bool, error) {
+	now := time.Now().UTC()
+	row := lockLease{Name: l.name, Owner: l.owner, ExpiresAt: now.Add(l.ttl)}
+
+	result := l.table(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"owner", "expires_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Lt{Column: clause.Column{Table: DefaultLockTable, Name: "expires_at"}, Value: now}}},
+	}).Create(&row)
+	if result.Error != nil {
+		return false, fmt.Errorf("db: acquiring lock %s: %w", l.name, result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (l *leaseLock) refresh(ctx context.Context, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	result := l.table(ctx).
+		Where("name = ? AND owner = ? AND expires_at >= ?", l.name, l.owner, now).
+		Update("expires_at", now.Add(ttl))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (l *leaseLock) held(ctx context.Context) (bool, error) {
+	var count int64
+	err := l.table(ctx).
+		Where("name = ? AND owner = ? AND expires_at >= ?", l.name, l.owner, time.Now().UTC()).
+		Count(&count).Error
+	return count == 1, err
+}
+
+func (l *leaseLock) release(ctx context.Context) error {
+	return l.table(ctx).Where("name = ? AND owner = ?", l.name, l.owner).Delete(&lockLease{}).Error
+}
@@ -0,0 +1,236 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func openLocks(t *testing.T) *gorm.DB {
+	t.Helper()
+	return openTestDB(t, sqliteMemory, nil)
+}
+
+func waitLost(t *testing.T, lease *Lease) {
+	t.Helper()
+
+	select {
+	case <-lease.Lost():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected lock %s to be lost", lease.Name())
+	}
+}
+
+func TestTryLockIsExclusive(t *testing.T) {
+	conn := openLocks(t)
+	ctx := context.Background()
+
+	first, err := TryLock(ctx, conn, "nightly", time.Minute)
+	if err != nil {
+		t.Fatalf("TryLock: %v", err)
+	}
+	if _, err := TryLock(ctx, conn, "nightly", time.Minute); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("expected ErrLockHeld, got %v", err)
+	}
+	other, err := TryLock(ctx, conn, "hourly", time.Minute)
+	if err != nil {
+		t.Fatalf("locks with other names should be independent: %v", err)
+	}
+	defer other.Unlock(ctx)
+
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatalf("second Unlock: %v", err)
+	}
+	second, err := TryLock(ctx, conn, "nightly", time.Minute)
+	if err != nil {
+		t.Fatalf("expected the lock to be free after Unlock: %v", err)
+	}
+	defer second.Unlock(ctx)
+
+	select {
+	case <-first.Lost():
+		t.Fatalf("Unlock should not report the lock as lost")
+	default:
+	}
+}
+
+func TestLockWaitsForRelease(t *testing.T) {
+	conn := openLocks(t)
+	ctx := context.Background()
+
+	held, err := TryLock(ctx, conn, "job", time.Minute)
+	if err != nil {
+		t.Fatalf("TryLock: %v", err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = held.Unlock(ctx)
+	}()
+
+	waiting, err := Lock(ctx, conn, "job", time.Minute)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	defer waiting.Unlock(ctx)
+
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := Lock(timeout, conn, "job", time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the wait to end with the context, got %v", err)
+	}
+}
+
+func TestLeaseExpiresWithoutRefresh(t *testing.T) {
+	conn := openLocks(t)
+	ctx := context.Background()
+
+	lease, err := TryLock(ctx, conn, "job", 150*time.Millisecond)
+	if err != nil {
+		t.Fatalf("TryLock: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		time.Sleep(75 * time.Millisecond)
+		if err := lease.Refresh(ctx); err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+	}
+
+	waitLost(t, lease)
+	if err := lease.Refresh(ctx); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("expected ErrLockLost, got %v", err)
+	}
+	next, err := TryLock(ctx, conn, "job", time.Minute)
+	if err != nil {
+		t.Fatalf("expected an expired lock to be free: %v", err)
+	}
+	defer next.Unlock(ctx)
+}
+
+func TestLeaseTakeoverIsDetected(t *testing.T) {
+	conn := openLocks(t)
+	ctx := context.Background()
+
+	lease, err := TryLock(ctx, conn, "job", 90*time.Millisecond)
+	if err != nil {
+		t.Fatalf("TryLock: %v", err)
+	}
+	if err := conn.Table(DefaultLockTable).Where("name = ?", "job").Update("owner", "someone-else").Error; err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	waitLost(t, lease)
+	if err := lease.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock after loss: %v", err)
+	}
+	var owners int64
+	if err := conn.Table(DefaultLockTable).Where("owner = ?", "someone-else").Count(&owners).Error; err != nil || owners != 1 {
+		t.Fatalf("the new owner's lease should be kept, got %d (%v)", owners, err)
+	}
+}
+
+// stalledLock is a backend whose connection hangs after the lock is acquired.
+type stalledLock struct{}
+
+func (stalledLock) acquire(context.Context) (bool, error) { return true, nil }
+
+func (stalledLock) refresh(ctx context.Context, _ time.Duration) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func (stalledLock) held(ctx context.Context) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func (stalledLock) release(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestStalledConnectionDoesNotBlockLease(t *testing.T) {
+	ctx := context.Background()
+	lease := newLease("job", 100*time.Millisecond, stalledLock{})
+
+	started := time.Now()
+	if err := lease.Refresh(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Refresh to give up at the expiry, got %v", err)
+	}
+	waitLost(t, lease)
+	if err := lease.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("a stalled connection blocked the lease for %s", elapsed)
+	}
+}
+
+// slowRefreshLock is a backend whose refresh only returns once the lock is released.
+type slowRefreshLock struct {
+	refreshing chan struct{}
+	released   chan struct{}
+}
+
+func (slowRefreshLock) acquire(context.Context) (bool, error) { return true, nil }
+
+func (s slowRefreshLock) refresh(ctx context.Context, _ time.Duration) (bool, error) {
+	close(s.refreshing)
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-s.released:
+		return true, nil
+	}
+}
+
+func (slowRefreshLock) held(context.Context) (bool, error) { return true, nil }
+
+func (s slowRefreshLock) release(context.Context) error {
+	close(s.released)
+	return nil
+}
+
+func TestUnlockDoesNotWaitForRefresh(t *testing.T) {
+	backend := slowRefreshLock{refreshing: make(chan struct{}), released: make(chan struct{})}
+	lease := newLease("job", time.Minute, backend)
+
+	refreshed := make(chan error, 1)
+	go func() { refreshed <- lease.Refresh(context.Background()) }()
+	<-backend.refreshing
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := lease.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := <-refreshed; !errors.Is(err, ErrLockLost) {
+		t.Fatalf("expected the refresh overtaken by Unlock to report the lock lost, got %v", err)
+	}
+	select {
+	case <-lease.Lost():
+		t.Fatalf("Unlock should not close Lost")
+	default:
+	}
+}
+
+func TestMySQLLockNameIsNamespacedAndBounded(t *testing.T) {
+	long := strings.Repeat("x", 200)
+	name := mysqlLockName("billing", long)
+	if len(name) > 64 {
+		t.Fatalf("GET_LOCK names are limited to 64 characters, got %d", len(name))
+	}
+	if name == mysqlLockName("shipping", long) {
+		t.Fatalf("locks of different databases should not collide")
+	}
+	if name != mysqlLockName("billing", long) {
+		t.Fatalf("the lock name should be stable")
+	}
+}
@@ -0,0 +1,327 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	ulog "github.com/unknowns24/uker/uker/log"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// DefaultSlowThreshold is the duration above which a query is reported as slow.
+const DefaultSlowThreshold = 200 * time.Millisecond
+
+// Redacted replaces sensitive bound values in logged statements.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactedColumns lists the column name fragments whose bound values are redacted.
+var DefaultRedactedColumns = []string{"password", "passwd", "secret", "token", "api_key", "apikey"}
+
+// LoggerConfig configures the GORM logger returned by NewLogger.
+type LoggerConfig struct {
+	// Level is the GORM log level. Zero means gormlogger.Warn: errors and slow queries.
+	Level gormlogger.LogLevel
+	// SlowThreshold reports queries slower than it as warnings. Zero uses
+	// DefaultSlowThreshold and a negative value disables slow query reporting.
+	SlowThreshold time.Duration
+	// IgnoreRecordNotFound skips gorm.ErrRecordNotFound errors.
+	IgnoreRecordNotFound bool
+	// LogParams includes bound values in the logged SQL. When false every value is left as a
+	// placeholder.
+	LogParams bool
+	// RedactColumns lists column name fragments whose values are replaced by Redacted when
+	// LogParams is enabled. Nil uses DefaultRedactedColumns.
+	RedactColumns []string
+}
+
+type gormLogger struct {
+	logger *logrus.Logger
+	base   *ulog.Logger
+	config LoggerConfig
+}
+
+// WithLogger makes the Connector, including its replicas, log through logger, usually one
+// created with NewLogger.
+func WithLogger(logger gormlogger.Interface) Option {
+	return func(c *Connector) {
+		c.logger = logger
+	}
+}
+
+// NewLogger returns a GORM logger writing SQL traces to logger with the duration, affected
+// rows, caller and the request and trace ids of the context (see log.WithRequestID).
+func NewLogger(logger *ulog.Logger, config LoggerConfig) gormlogger.Interface {
+	if config.Level == 0 {
+		config.Level = gormlogger.Warn
+	}
+	if config.SlowThreshold == 0 {
+		config.SlowThreshold = DefaultSlowThreshold
+	}
+	if config.RedactColumns == nil {
+		config.RedactColumns = DefaultRedactedColumns
+	}
+
+	l := &gormLogger{base: logger, config: config}
+	if logger == nil || logger.Logger == nil {
+		l.base = nil
+		l.logger = logrus.StandardLogger()
+	}
+	return l
+}
+
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	clone := *l
+	clone.config.Level = level
+	return &clone
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...any) {
+	if l.config.Level >= gormlogger.Info {
+		l.entry(ctx).Infof(msg, args...)
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	if l.config.Level >= gormlogger.Warn {
+		l.entry(ctx).Warnf(msg, args...)
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...any) {
+	if l.config.Level >= gormlogger.Error {
+		l.entry(ctx).Errorf(msg, args...)
+	}
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.config.Level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	failed := err != nil && !(l.config.IgnoreRecordNotFound && errors.Is(err, gorm.ErrRecordNotFound))
+	slow := l.config.SlowThreshold > 0 && elapsed > l.config.SlowThreshold
+
+	switch {
+	case failed && l.config.Level >= gormlogger.Error:
+		l.traceEntry(ctx, elapsed, fc).WithError(err).Error("sql error")
+	case slow && l.config.Level >= gormlogger.Warn:
+		l.traceEntry(ctx, elapsed, fc).WithField("slow_threshold_ms", milliseconds(l.config.SlowThreshold)).Warn("slow sql")
+	case l.config.Level >= gormlogger.Info:
+		l.traceEntry(ctx, elapsed, fc).Info("sql")
+	}
+}
+
+// ParamsFilter is called by GORM before formatting the logged statement. It hides every
+// bound value unless LogParams is set, and otherwise redacts the sensitive ones.
+func (l *gormLogger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	if !l.config.LogParams {
+		return sql, nil
+	}
+
+	filtered := append([]any(nil), params...)
+	for i, column := range placeholderColumns(sql, len(params)) {
+		if l.sensitive(column) {
+			filtered[i] = Redacted
+		}
+	}
+	return sql, filtered
+}
+
+func (l *gormLogger) entry(ctx context.Context) *logrus.Entry {
+	if l.base != nil {
+		return l.base.WithContext(ctx)
+	}
+	return logrus.NewEntry(l.logger).WithContext(ctx)
+}
+
+func (l *gormLogger) traceEntry(ctx context.Context, elapsed time.Duration, fc func() (string, int64)) *logrus.Entry {
+	sql, rows := fc()
+	fields := logrus.Fields{
+		"sql":         sql,
+		"duration_ms": milliseconds(elapsed),
+		"caller":      caller(),
+	}
+	if rows >= 0 {
+		fields["rows"] = rows
+	}
+	return l.entry(ctx).WithFields(fields)
+}
+
+func (l *gormLogger) sensitive(column string) bool {
+	if column == "" {
+		return false
+	}
+	for _, fragment := range l.config.RedactColumns {
+		if fragment != "" && strings.Contains(column, strings.ToLower(fragment)) {
+			return true
+		}
+	}
+	return false
+}
+
+// caller returns the file and line of the first frame outside GORM and this package, so
+// statements issued through helpers such as WithTx point at application code.
+func caller() string {
+	pcs := [16]uintptr{}
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		internal := strings.Contains(frame.File, "gorm.io/") ||
+			(strings.HasPrefix(frame.Function, packagePath+".") && !strings.HasSuffix(frame.File, "_test.go"))
+		if !internal && frame.File != "" {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+var packagePath = reflect.TypeOf(gormLogger{}).PkgPath()
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Nanoseconds()) / 1e6
+}
+
+// placeholderColumns maps every bound value of sql to the column it is compared with or
+// assigned to, e.g. "users.password = ?" or the column list of an INSERT. Values whose column
+// cannot be inferred get an empty name.
+func placeholderColumns(sql string, n int) []string {
+	columns := make([]string, n)
+
+	var (
+		last       string
+		insertCols []string
+		collecting bool
+		inValues   bool
+		depth      int
+		position   int
+		next       int
+	)
+
+	assign := func(index int, column string) {
+		if index >= 0 && index < n {
+			columns[index] = column
+		}
+	}
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\'':
+			i = skipQuoted(sql, i, '\'')
+		case c == '`' || c == '"':
+			end := skipQuoted(sql, i, c)
+			last = strings.ToLower(strings.TrimSuffix(sql[i+1:end], string(c)))
+			if collecting && depth == 1 {
+				insertCols = append(insertCols, last)
+			}
+			i = end
+		case c == '(':
+			depth++
+			if inValues && depth == 1 {
+				position = 0
+			}
+			i++
+		case c == ')':
+			depth--
+			if collecting && depth == 0 {
+				collecting = false
+			}
+			i++
+		case c == ',':
+			if inValues && depth == 1 {
+				position++
+			}
+			i++
+		case c == '?' || (c == '$' && i+1 < len(sql) && isDigit(sql[i+1])):
+			index := next
+			i++
+			if c == '$' {
+				start := i
+				for i < len(sql) && isDigit(sql[i]) {
+					i++
+				}
+				number, _ := strconv.Atoi(sql[start:i])
+				index = number - 1
+			}
+			next++
+
+			column := last
+			if inValues && depth == 1 {
+				column = ""
+				if position < len(insertCols) {
+					column = insertCols[position]
+				}
+			}
+			assign(index, column)
+		case isIdentStart(c):
+			start := i
+			for i < len(sql) && (isIdentStart(sql[i]) || isDigit(sql[i]) || sql[i] == '.') {
+				i++
+			}
+			word := strings.ToLower(sql[start:i])
+			if dot := strings.LastIndexByte(word, '.'); dot >= 0 {
+				word = word[dot+1:]
+			}
+
+			switch word {
+			case "insert":
+				collecting, insertCols = true, nil
+			case "values":
+				collecting, inValues = false, true
+			case "on", "returning", "select", "update", "where":
+				inValues = false
+				last = ""
+			case "limit", "offset":
+				last = ""
+			default:
+				if !sqlKeywords[word] {
+					last = word
+					if collecting && depth == 1 {
+						insertCols = append(insertCols, word)
+					}
+				}
+			}
+		default:
+			i++
+		}
+	}
+
+	return columns
+}
+
+var sqlKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "in": true, "is": true, "null": true, "like": true,
+	"ilike": true, "between": true, "set": true, "into": true, "from": true, "as": true,
+	"any": true, "all": true, "default": true, "interval": true,
+}
+
+func skipQuoted(sql string, i int, quote byte) int {
+	for j := i + 1; j < len(sql); j++ {
+		if sql[j] == quote {
+			if j+1 < len(sql) && sql[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(sql)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
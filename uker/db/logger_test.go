@@ -0,0 +1,121 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	ulog "github.com/unknowns24/uker/uker/log"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type account struct {
+	ID       uint
+	Email    string
+	Password string
+}
+
+func captureLogger(config LoggerConfig) (gormlogger.Interface, *bytes.Buffer) {
+	var out bytes.Buffer
+	base := logrus.New()
+	base.SetOutput(&out)
+	base.SetFormatter(&logrus.JSONFormatter{})
+	base.SetLevel(logrus.DebugLevel)
+	return NewLogger(&ulog.Logger{Logger: base}, config), &out
+}
+
+func decodeLines(t *testing.T, out *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		entry := map[string]any{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLoggerTracesWithContextAndRedaction(t *testing.T) {
+	logger, out := captureLogger(LoggerConfig{Level: gormlogger.Info, LogParams: true})
+
+	conn, err := newSQLite(sqliteDialect{Path: sqliteMemory}, WithLogger(logger)).Open(&account{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	out.Reset()
+
+	ctx := ulog.WithTraceID(ulog.WithRequestID(context.Background(), "req-1"), "trace-1")
+	if err := conn.WithContext(ctx).Create(&account{Email: "a@example.com", Password: "hunter2"}).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if strings.Contains(out.String(), "hunter2") {
+		t.Fatalf("password leaked into logs: %s", out.String())
+	}
+
+	entries := decodeLines(t, out)
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry, got %d: %s", len(entries), out.String())
+	}
+	entry := entries[0]
+	if entry[ulog.FieldRequestID] != "req-1" || entry[ulog.FieldTraceID] != "trace-1" {
+		t.Fatalf("missing context ids: %v", entry)
+	}
+	if entry["rows"] != float64(1) || !strings.Contains(entry["caller"].(string), "logger_test.go") {
+		t.Fatalf("unexpected rows or caller: %v", entry)
+	}
+	if sql := entry["sql"].(string); !strings.Contains(sql, "a@example.com") || !strings.Contains(sql, Redacted) {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+}
+
+func TestLoggerHidesParamsAndReportsSlowQueries(t *testing.T) {
+	logger, out := captureLogger(LoggerConfig{SlowThreshold: time.Nanosecond})
+
+	conn, err := newSQLite(sqliteDialect{Path: sqliteMemory}, WithLogger(logger)).Open(&account{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	out.Reset()
+
+	var found account
+	conn.Where("email = ?", "a@example.com").Find(&found)
+
+	entries := decodeLines(t, out)
+	if len(entries) != 1 || entries[0]["msg"] != "slow sql" || entries[0]["level"] != "warning" {
+		t.Fatalf("expected one slow query warning, got %s", out.String())
+	}
+	if sql := entries[0]["sql"].(string); strings.Contains(sql, "a@example.com") || !strings.Contains(sql, "?") {
+		t.Fatalf("bound values should be hidden: %s", sql)
+	}
+}
+
+func TestPlaceholderColumns(t *testing.T) {
+	cases := []struct {
+		sql  string
+		n    int
+		want []string
+	}{
+		{"SELECT * FROM `users` WHERE `users`.`email` = ? AND password_hash = ? LIMIT ?", 3, []string{"email", "password_hash", ""}},
+		{"INSERT INTO `users` (`email`,`api_token`) VALUES (?,?),(?,?)", 4, []string{"email", "api_token", "email", "api_token"}},
+		{`UPDATE "users" SET "secret"=$2 WHERE "id" IN ($1, 'it''s ?')`, 2, []string{"id", "secret"}},
+		{`INSERT INTO t (a, b) VALUES (NOW(), ?) ON CONFLICT (a) DO UPDATE SET token = ?`, 2, []string{"b", "token"}},
+	}
+
+	for _, tc := range cases {
+		if got := placeholderColumns(tc.sql, tc.n); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("placeholderColumns(%q) = %q, want %q", tc.sql, got, tc.want)
+		}
+	}
+}
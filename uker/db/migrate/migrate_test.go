@@ -0,0 +1,243 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/unknowns24/uker/uker/db"
+	"github.com/unknowns24/uker/uker/db/sqlite"
+	"gorm.io/gorm"
+)
+
+var testFS = fstest.MapFS{
+	"migrations/0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL);\n-- seed\nINSERT INTO users (id, email) VALUES (1, 'a;b@example.com');\n")},
+	"migrations/0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	"migrations/0002_add_name.up.sql":       {Data: []byte("ALTER TABLE users ADD COLUMN name TEXT;")},
+	"migrations/README.md":                  {Data: []byte("ignored")},
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	conn, err := sqlite.New(sqlite.ConnData{Path: sqlite.Memory}).Open()
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	return conn
+}
+
+func TestUpDownAndStatus(t *testing.T) {
+	ctx := context.Background()
+	conn := openTestDB(t)
+
+	backfill := Migration{
+		Version: 3,
+		Name:    "backfill_names",
+		Up: func(ctx context.Context, tx *gorm.DB) error {
+			return tx.Exec("UPDATE users SET name = 'unknown' WHERE name IS NULL").Error
+		},
+		Down: func(ctx context.Context, tx *gorm.DB) error {
+			return tx.Exec("UPDATE users SET name = NULL").Error
+		},
+	}
+
+	migrator, err := New(conn, WithFS(testFS, "migrations"), WithMigrations(backfill))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if versions(applied) != "1,2,3" {
+		t.Fatalf("applied = %s", versions(applied))
+	}
+
+	var name string
+	if err := conn.Raw("SELECT name FROM users WHERE id = 1").Scan(&name).Error; err != nil || name != "unknown" {
+		t.Fatalf("expected backfilled name, got %q (%v)", name, err)
+	}
+
+	if applied, err := migrator.Up(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("second Up applied %d migrations (%v)", len(applied), err)
+	}
+
+	reverted, err := migrator.Down(ctx, 1)
+	if err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if versions(reverted) != "3" {
+		t.Fatalf("reverted = %s", versions(reverted))
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	applies := make([]bool, 0, len(statuses))
+	for _, status := range statuses {
+		applies = append(applies, status.Applied)
+	}
+	if !reflect.DeepEqual(applies, []bool{true, true, false}) {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+
+	if _, err := migrator.Down(ctx, 1); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("expected ErrIrreversible, got %v", err)
+	}
+}
+
+func TestConcurrentUpOnEmptyDatabase(t *testing.T) {
+	ctx := context.Background()
+	conn, err := sqlite.New(sqlite.ConnData{Path: filepath.Join(t.TempDir(), "app.db") + "?_busy_timeout=5000"}).Open()
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close(conn)
+
+	start := make(chan struct{})
+	results := make(chan []Migration, 2)
+	errs := make(chan error, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		migrator, err := New(conn, WithFS(testFS, "migrations"))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			applied, err := migrator.Up(ctx)
+			results <- applied
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(results)
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent Up: %v", err)
+		}
+	}
+	total := 0
+	for applied := range results {
+		total += len(applied)
+	}
+	if total != 2 {
+		t.Fatalf("expected each migration to be applied once, got %d applications", total)
+	}
+}
+
+func TestUpReadsVersionsFromPrimary(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	primary := sqlite.ConnData{Path: filepath.Join(dir, "primary.db")}
+	lagging := sqlite.ConnData{Path: filepath.Join(dir, "replica.db")}
+
+	conn, err := sqlite.New(primary).Open()
+	if err != nil {
+		t.Fatalf("open primary: %v", err)
+	}
+	migrator, err := New(conn, WithFS(testFS, "migrations"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	db.Close(conn)
+
+	conn, err = sqlite.New(primary, db.WithReplicas(db.ReplicaRoundRobin, lagging)).Open()
+	if err != nil {
+		t.Fatalf("open with replica: %v", err)
+	}
+	defer db.Close(conn)
+
+	noop := Migration{Version: 3, Name: "noop", Up: func(context.Context, *gorm.DB) error { return nil }}
+	migrator, err = New(conn, WithFS(testFS, "migrations"), WithMigrations(noop))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up with a lagging replica: %v", err)
+	}
+	if versions(applied) != "3" {
+		t.Fatalf("applied = %s", versions(applied))
+	}
+}
+
+func TestDryRunDoesNotTouchDatabase(t *testing.T) {
+	conn := openTestDB(t)
+
+	var out strings.Builder
+	migrator, err := New(conn, WithFS(testFS, "migrations"), WithDryRun(&out))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "CREATE TABLE users") || !strings.Contains(out.String(), "-- 2_add_name (up)") {
+		t.Fatalf("unexpected dry run output:\n%s", out.String())
+	}
+	if conn.Migrator().HasTable("users") || conn.Migrator().HasTable(DefaultTable) {
+		t.Fatalf("dry run created tables")
+	}
+}
+
+func TestNewRejectsDuplicatedVersions(t *testing.T) {
+	conn := openTestDB(t)
+	duplicate := Migration{Version: 1, Name: "other", UpSQL: "SELECT 1"}
+
+	if _, err := New(conn, WithFS(testFS, "migrations"), WithMigrations(duplicate)); err == nil {
+		t.Fatalf("expected duplicated version error")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `
+-- leading comment
+CREATE FUNCTION touch() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at = now();
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+/* block; comment */
+INSERT INTO notes (body) VALUES ('semi;colon'), ("double;quoted");
+-- trailing comment only
+`
+	statements := splitStatements(script)
+	if len(statements) != 2 {
+		t.Fatalf("expected 2 statements, got %d: %q", len(statements), statements)
+	}
+	if !strings.HasSuffix(statements[0], "LANGUAGE plpgsql") {
+		t.Fatalf("unexpected first statement: %q", statements[0])
+	}
+	if !strings.Contains(statements[1], "'semi;colon'") {
+		t.Fatalf("unexpected second statement: %q", statements[1])
+	}
+}
+
+func versions(migrations []Migration) string {
+	parts := make([]string, 0, len(migrations))
+	for _, migration := range migrations {
+		parts = append(parts, strconv.FormatInt(migration.Version, 10))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,209 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrIrreversible is returned by Down when a migration has no down step.
+var ErrIrreversible = errors.New("migrate: migration has no down step")
+
+var fileNameRe = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_\-]+)\.(up|down)\.sql$`)
+
+// Func is a migration step written in Go. It runs inside the migration transaction.
+type Func func(ctx context.Context, tx *gorm.DB) error
+
+// Migration is a single versioned schema change. A step is either SQL (UpSQL / DownSQL) or a
+// Go function (Up / Down); when both are present the function wins.
+type Migration struct {
+	Version int64
+	Name    string
+	UpSQL   string
+	DownSQL string
+	Up      Func
+	Down    Func
+}
+
+func (m Migration) hasDown() bool {
+	return m.Down != nil || m.DownSQL != ""
+}
+
+// LoadFS reads migrations from dir inside fsys (typically an embed.FS). Files must be named
+// <version>_<name>.up.sql and <version>_<name>.down.sql; the down file is optional.
+func LoadFS(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: reading %s: %w", dir, err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := fileNameRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: invalid version in %s: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("migrate: reading %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d used by %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.UpSQL = string(content)
+		} else {
+			migration.DownSQL = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.UpSQL == "" {
+			return nil, fmt.Errorf("migrate: version %d (%s) has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sortMigrations(migrations)
+	return migrations, nil
+}
+
+func sortMigrations(migrations []Migration) {
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
+
+// splitStatements splits a SQL script into individual statements on semicolons, ignoring
+// those inside quotes, comments and PostgreSQL dollar-quoted bodies. Most drivers reject
+// multiple statements per Exec by default.
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		start      int
+		dollarTag  string
+	)
+
+	flush := func(end int) {
+		if statement := trimSQL(script[start:end]); statement != "" {
+			statements = append(statements, statement)
+		}
+		start = end + 1
+	}
+
+	for i := 0; i < len(script); i++ {
+		if dollarTag != "" {
+			if hasPrefixAt(script, i, dollarTag) {
+				i += len(dollarTag) - 1
+				dollarTag = ""
+			}
+			continue
+		}
+
+		switch c := script[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			for i++; i < len(script) && script[i] != c; i++ {
+				if script[i] == '\\' {
+					i++
+				}
+			}
+		case c == '-' && hasPrefixAt(script, i, "--"):
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+		case c == '/' && hasPrefixAt(script, i, "/*"):
+			end := indexFrom(script, i+2, "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i = end + 1
+			}
+		case c == '$':
+			if tag := dollarQuoteTag(script, i); tag != "" {
+				dollarTag = tag
+				i += len(tag) - 1
+			}
+		case c == ';':
+			flush(i)
+		}
+	}
+
+	if start < len(script) {
+		flush(len(script))
+	}
+	return statements
+}
+
+func dollarQuoteTag(script string, i int) string {
+	for j := i + 1; j < len(script); j++ {
+		c := script[j]
+		if c == '$' {
+			return script[i : j+1]
+		}
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
+
+func hasPrefixAt(s string, i int, prefix string) bool {
+	return strings.HasPrefix(s[i:], prefix)
+}
+
+func indexFrom(s string, from int, substr string) int {
+	if idx := strings.Index(s[from:], substr); idx >= 0 {
+		return from + idx
+	}
+	return -1
+}
+
+// trimSQL removes surrounding whitespace and reports an empty string for comment-only chunks.
+func trimSQL(statement string) string {
+	trimmed := strings.TrimSpace(statement)
+	remaining := trimmed
+	for remaining != "" {
+		switch {
+		case strings.HasPrefix(remaining, "--"):
+			end := strings.Index(remaining, "\n")
+			if end < 0 {
+				return ""
+			}
+			remaining = strings.TrimSpace(remaining[end+1:])
+		case strings.HasPrefix(remaining, "/*"):
+			end := strings.Index(remaining, "*/")
+			if end < 0 {
+				return ""
+			}
+			remaining = strings.TrimSpace(remaining[end+2:])
+		default:
+			return trimmed
+		}
+	}
+	return ""
+}
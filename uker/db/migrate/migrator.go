@@ -0,0 +1,339 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/unknowns24/uker/uker/db"
+	"gorm.io/gorm"
+)
+
+// DefaultTable is the table recording applied versions.
+const DefaultTable = "schema_migrations"
+
+var errNilDB = errors.New("migrate: nil db")
+
+// Option configures a Migrator.
+type Option func(*Migrator) error
+
+// WithFS adds the SQL migrations found in dir inside fsys (see LoadFS).
+func WithFS(fsys fs.FS, dir string) Option {
+	return func(m *Migrator) error {
+		migrations, err := LoadFS(fsys, dir)
+		if err != nil {
+			return err
+		}
+		m.migrations = append(m.migrations, migrations...)
+		return nil
+	}
+}
+
+// WithMigrations adds migrations declared in code, e.g. Go functions backfilling data.
+func WithMigrations(migrations ...Migration) Option {
+	return func(m *Migrator) error {
+		m.migrations = append(m.migrations, migrations...)
+		return nil
+	}
+}
+
+// WithTable overrides the table recording applied versions.
+func WithTable(table string) Option {
+	return func(m *Migrator) error {
+		if table == "" {
+			return errors.New("migrate: empty table name")
+		}
+		m.table = table
+		return nil
+	}
+}
+
+// WithDryRun makes Up and Down print the statements they would run to w without executing
+// them or recording versions.
+func WithDryRun(w io.Writer) Option {
+	return func(m *Migrator) error {
+		m.dryRun = w
+		return nil
+	}
+}
+
+// Status reports whether a known migration has been applied.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Migrator applies versioned migrations and records them in a table.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	table      string
+	dryRun     io.Writer
+}
+
+type appliedVersion struct {
+	Version   int64     `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;size:255;not null"`
+	AppliedAt time.Time `gorm:"column:applied_at;not null"`
+}
+
+// New creates a Migrator for the given connection. Versions must be unique across sources.
+func New(gdb *gorm.DB, opts ...Option) (*Migrator, error) {
+	if gdb == nil {
+		return nil, errNilDB
+	}
+
+	m := &Migrator{db: gdb, table: DefaultTable}
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if err := opt(m); err != nil {
+			return nil, err
+		}
+	}
+
+	sortMigrations(m.migrations)
+	for i := 1; i < len(m.migrations); i++ {
+		if m.migrations[i].Version == m.migrations[i-1].Version {
+			return nil, fmt.Errorf("migrate: duplicated version %d", m.migrations[i].Version)
+		}
+	}
+	for _, migration := range m.migrations {
+		if migration.Up == nil && migration.UpSQL == "" {
+			return nil, fmt.Errorf("migrate: version %d (%s) has no up step", migration.Version, migration.Name)
+		}
+	}
+
+	return m, nil
+}
+
+// Migrations returns the known migrations in version order.
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration(nil), m.migrations...)
+}
+
+// Up applies every pending migration in version order and returns the applied ones.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(gdb *gorm.DB) error {
+		done, err := m.appliedVersions(gdb)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if err := m.run(ctx, gdb, migration, true); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the last n applied migrations, newest first, and returns them.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(ctx, func(gdb *gorm.DB) error {
+		done, err := m.appliedVersions(gdb)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < n; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if !migration.hasDown() {
+				return fmt.Errorf("%w: version %d (%s)", ErrIrreversible, migration.Version, migration.Name)
+			}
+			if err := m.run(ctx, gdb, migration, false); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	done, err := m.appliedVersions(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := done[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) run(ctx context.Context, gdb *gorm.DB, migration Migration, up bool) error {
+	fn, script := migration.Up, migration.UpSQL
+	direction := "up"
+	if !up {
+		fn, script = migration.Down, migration.DownSQL
+		direction = "down"
+	}
+
+	if m.dryRun != nil {
+		fmt.Fprintf(m.dryRun, "-- %d_%s (%s)\n", migration.Version, migration.Name, direction)
+		if fn != nil {
+			fmt.Fprintln(m.dryRun, "-- go migration")
+			return nil
+		}
+		for _, statement := range splitStatements(script) {
+			fmt.Fprintf(m.dryRun, "%s;\n", statement)
+		}
+		return nil
+	}
+
+	err := gdb.Transaction(func(tx *gorm.DB) error {
+		if fn != nil {
+			if err := fn(ctx, tx); err != nil {
+				return err
+			}
+		} else {
+			for _, statement := range splitStatements(script) {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if up {
+			return tx.Table(m.table).Create(&appliedVersion{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+		}
+		return tx.Table(m.table).Where("version = ?", migration.Version).Delete(&appliedVersion{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate: %s %d (%s): %w", direction, migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) ensureTable(gdb *gorm.DB) error {
+	if err := gdb.Table(m.table).AutoMigrate(&appliedVersion{}); err != nil {
+		return fmt.Errorf("migrate: creating %s: %w", m.table, err)
+	}
+	return nil
+}
+
+func (m *Migrator) appliedVersions(gdb *gorm.DB) (map[int64]appliedVersion, error) {
+	if !gdb.Migrator().HasTable(m.table) {
+		return map[int64]appliedVersion{}, nil
+	}
+
+	var records []appliedVersion
+	if err := gdb.Table(m.table).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("migrate: reading %s: %w", m.table, err)
+	}
+
+	done := make(map[int64]appliedVersion, len(records))
+	for _, record := range records {
+		done[record.Version] = record
+	}
+	return done, nil
+}
+
+// withLock serialises migrations across processes with a database level lock held on a
+// dedicated connection: GET_LOCK on MySQL and an advisory lock on PostgreSQL. SQLite, which
+// has no named locks, is only serialised within the process. The versions table is created
+// once the lock is held so processes starting together on an empty database do not race to
+// create it. Dry runs neither lock nor create the versions table.
+func (m *Migrator) withLock(ctx context.Context, fn func(gdb *gorm.DB) error) error {
+	// Migrations and the versions they record must be read from the primary, never from a
+	// replica lagging behind it.
+	gdb := m.db.WithContext(db.UsePrimary(ctx))
+	if m.dryRun != nil {
+		return fn(gdb)
+	}
+
+	sqlDB, err := gdb.DB()
+	if err != nil {
+		return err
+	}
+
+	driver := db.DriverOf(gdb)
+	if driver != db.DriverMySQL && driver != db.DriverPostgres {
+		mu, _ := localLocks.LoadOrStore(sqlDB, &sync.Mutex{})
+		mu.(*sync.Mutex).Lock()
+		defer mu.(*sync.Mutex).Unlock()
+	} else {
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		release, err := m.lock(ctx, conn, driver)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	if err := m.ensureTable(gdb); err != nil {
+		return err
+	}
+	return fn(gdb)
+}
+
+// localLocks holds the mutex serialising the migrations of each SQLite pool.
+var localLocks sync.Map
+
+// mysqlLockName namespaces the GET_LOCK name with the current database: named locks are
+// server wide, so services migrating other schemas of the same server must not share it.
+const mysqlLockName = "CONCAT(DATABASE(), '.', ?)"
+
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn, driver db.Driver) (func(), error) {
+	if driver == db.DriverPostgres {
+		key := lockKey(m.table)
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+			return nil, fmt.Errorf("migrate: acquiring lock: %w", err)
+		}
+		return func() {
+			_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		}, nil
+	}
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK("+mysqlLockName+", -1)", m.table).Scan(&acquired); err != nil {
+		return nil, fmt.Errorf("migrate: acquiring lock: %w", err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return nil, errors.New("migrate: could not acquire lock")
+	}
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK("+mysqlLockName+")", m.table)
+	}, nil
+}
+
+func lockKey(name string) int64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte("uker.migrate." + name))
+	return int64(hash.Sum64())
+}
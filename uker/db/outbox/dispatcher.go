@@ -0,0 +1,232 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/unknowns24/uker/uker/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Dispatcher defaults.
+const (
+	DefaultPollInterval    = time.Second
+	DefaultBatchSize       = 100
+	DefaultMaxAttempts     = 10
+	DefaultInitialBackoff  = time.Second
+	DefaultMaxBackoff      = 5 * time.Minute
+	DefaultPublishTimeout  = 30 * time.Second
+	DefaultRetention       = 7 * 24 * time.Hour
+	DefaultCleanupInterval = time.Hour
+)
+
+const maxErrorLength = 1024
+
+var errNilPublisher = errors.New("outbox: nil publisher")
+
+// Config tunes a Dispatcher. Zero values use the package defaults.
+type Config struct {
+	// PollInterval is the wait between polls when the previous one found no messages.
+	PollInterval time.Duration
+	// BatchSize is the maximum number of messages claimed and published per poll.
+	BatchSize int
+	// MaxAttempts stops retrying a message after that many failed deliveries. The message
+	// stays in the table with its last error for manual inspection.
+	MaxAttempts int
+	// InitialBackoff is the delay before retrying a failed message; it doubles on each
+	// attempt up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// PublishTimeout bounds each Publish call, so a hung broker fails the message and
+	// cannot block the dispatcher, or its shutdown, forever.
+	PublishTimeout time.Duration
+	// Retention is how long sent messages are kept before Cleanup deletes them. A negative
+	// value disables the periodic cleanup.
+	Retention time.Duration
+	// CleanupInterval is the wait between cleanups run by Run.
+	CleanupInterval time.Duration
+	// ErrorHandler receives the errors Run cannot return, e.g. a failed poll.
+	ErrorHandler func(error)
+}
+
+// Dispatcher publishes the messages stored with Add.
+type Dispatcher struct {
+	db        *gorm.DB
+	publisher Publisher
+	config    Config
+}
+
+// NewDispatcher creates a Dispatcher delivering the outbox of gdb through publisher.
+func NewDispatcher(gdb *gorm.DB, publisher Publisher, config Config) (*Dispatcher, error) {
+	if gdb == nil {
+		return nil, errNilDB
+	}
+	if publisher == nil {
+		return nil, errNilPublisher
+	}
+
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultPollInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = DefaultInitialBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+	if config.PublishTimeout <= 0 {
+		config.PublishTimeout = DefaultPublishTimeout
+	}
+	if config.Retention == 0 {
+		config.Retention = DefaultRetention
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = DefaultCleanupInterval
+	}
+
+	return &Dispatcher{db: gdb, publisher: publisher, config: config}, nil
+}
+
+// Run polls and publishes messages until ctx is cancelled and then returns ctx.Err(). The
+// batch in progress when that happens is completed, so no message is left published but not
+// marked as sent; PublishTimeout bounds how long that takes.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	poll := time.NewTimer(0)
+	defer poll.Stop()
+	cleanup := time.NewTicker(d.config.CleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-cleanup.C:
+			if d.config.Retention > 0 {
+				if _, err := d.Cleanup(context.WithoutCancel(ctx)); err != nil {
+					d.handleError(err)
+				}
+			}
+		case <-poll.C:
+			sent, err := d.DispatchOnce(context.WithoutCancel(ctx))
+			if err != nil {
+				d.handleError(err)
+			}
+
+			wait := d.config.PollInterval
+			if err == nil && sent == d.config.BatchSize {
+				wait = 0
+			}
+			poll.Reset(wait)
+		}
+	}
+}
+
+// DispatchOnce claims one batch of due messages, publishes them and records the outcome. It
+// returns the number of messages processed. Each Publish call is bounded by PublishTimeout and
+// runs outside any transaction, so a slow broker holds no row locks or connection.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	messages, err := d.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, message := range messages {
+		updates := map[string]any{}
+		if err := d.publish(ctx, message); err != nil {
+			updates["attempts"] = message.Attempts + 1
+			updates["last_error"] = truncate(err.Error(), maxErrorLength)
+			updates["available_at"] = d.db.NowFunc().Add(db.Backoff(message.Attempts, d.config.InitialBackoff, d.config.MaxBackoff))
+		} else {
+			sentAt := d.db.NowFunc()
+			updates["sent_at"] = &sentAt
+			updates["last_error"] = ""
+		}
+
+		if err := d.db.WithContext(ctx).Model(&Message{}).Where("id = ?", message.ID).Updates(updates).Error; err != nil {
+			return processed, err
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+// claim locks one batch of due messages with FOR UPDATE SKIP LOCKED, so several dispatchers
+// can run in parallel, and leases them by moving available_at past the time needed to publish
+// the whole batch. The claim commits before publishing; if the dispatcher dies mid-batch, its
+// unpublished messages become due again when the lease ends.
+func (d *Dispatcher) claim(ctx context.Context) ([]Message, error) {
+	var messages []Message
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := tx.NowFunc()
+		query := tx.Where("sent_at IS NULL AND available_at <= ? AND attempts < ?", now, d.config.MaxAttempts).
+			Order("id").
+			Limit(d.config.BatchSize)
+		if db.DriverOf(tx) != db.DriverSQLite {
+			query = query.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate, Options: clause.LockingOptionsSkipLocked})
+		}
+
+		if err := query.Find(&messages).Error; err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		ids := make([]uint64, len(messages))
+		for i, message := range messages {
+			ids[i] = message.ID
+		}
+		lease := now.Add(d.config.PublishTimeout * time.Duration(len(messages)))
+		return tx.Model(&Message{}).Where("id IN ?", ids).Update("available_at", lease).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (d *Dispatcher) publish(ctx context.Context, message Message) error {
+	ctx, cancel := context.WithTimeout(ctx, d.config.PublishTimeout)
+	defer cancel()
+	return d.publisher.Publish(ctx, message)
+}
+
+// Cleanup deletes the messages sent before the retention period and returns how many were
+// removed.
+func (d *Dispatcher) Cleanup(ctx context.Context) (int64, error) {
+	retention := d.config.Retention
+	if retention < 0 {
+		return 0, nil
+	}
+
+	cutoff := d.db.NowFunc().Add(-retention)
+	result := d.db.WithContext(ctx).Where("sent_at IS NOT NULL AND sent_at < ?", cutoff).Delete(&Message{})
+	return result.RowsAffected, result.Error
+}
+
+func (d *Dispatcher) handleError(err error) {
+	if d.config.ErrorHandler != nil {
+		d.config.ErrorHandler(err)
+	}
+}
+
+// truncate shortens value to at most max bytes without splitting a multi-byte character.
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	for max > 0 && !utf8.RuneStart(value[max]) {
+		max--
+	}
+	return value[:max]
+}
@@ -0,0 +1,77 @@
+// Package outbox implements the transactional outbox pattern: events are written with Add in
+// the same transaction as the business change and a Dispatcher delivers them afterwards, so an
+// event is published if and only if the change was committed.
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/unknowns24/uker/uker/db"
+	"gorm.io/gorm"
+)
+
+// DefaultTable stores the pending and sent messages.
+const DefaultTable = "outbox_messages"
+
+var (
+	errNilDB      = errors.New("outbox: nil db")
+	errEmptyTopic = errors.New("outbox: empty topic")
+)
+
+// Message is a row of the outbox table.
+type Message struct {
+	ID          uint64     `gorm:"column:id;primaryKey"`
+	Topic       string     `gorm:"column:topic;size:255;not null"`
+	Payload     []byte     `gorm:"column:payload;not null"`
+	Attempts    int        `gorm:"column:attempts;not null;default:0"`
+	LastError   string     `gorm:"column:last_error;size:1024"`
+	AvailableAt time.Time  `gorm:"column:available_at;not null;index:idx_outbox_pending,priority:2"`
+	SentAt      *time.Time `gorm:"column:sent_at;index:idx_outbox_pending,priority:1"`
+	CreatedAt   time.Time  `gorm:"column:created_at;not null"`
+}
+
+// TableName implements gorm's Tabler.
+func (Message) TableName() string {
+	return DefaultTable
+}
+
+// Migrate creates or updates the outbox table.
+func Migrate(gdb *gorm.DB) error {
+	if gdb == nil {
+		return errNilDB
+	}
+	return gdb.WithContext(db.UsePrimary(gdb.Statement.Context)).AutoMigrate(&Message{})
+}
+
+// Add stores an event in the outbox using tx, which should be the transaction of the business
+// change. Payloads of type []byte, string and json.RawMessage are stored as is; any other
+// value is encoded as JSON.
+func Add(tx *gorm.DB, topic string, payload any) error {
+	if tx == nil {
+		return errNilDB
+	}
+	if topic == "" {
+		return errEmptyTopic
+	}
+
+	var data []byte
+	switch value := payload.(type) {
+	case []byte:
+		data = value
+	case string:
+		data = []byte(value)
+	case json.RawMessage:
+		data = value
+	default:
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		data = encoded
+	}
+
+	now := tx.NowFunc()
+	return tx.Create(&Message{Topic: topic, Payload: data, AvailableAt: now, CreatedAt: now}).Error
+}
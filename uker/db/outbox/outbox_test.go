@@ -0,0 +1,246 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/unknowns24/uker/uker/db"
+	"github.com/unknowns24/uker/uker/db/sqlite"
+	"gorm.io/gorm"
+)
+
+type order struct {
+	ID    uint
+	Total int
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	conn, err := sqlite.New(sqlite.ConnData{Path: sqlite.Memory}).Open(&order{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := Migrate(conn); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return conn
+}
+
+func countPending(t *testing.T, conn *gorm.DB) int64 {
+	t.Helper()
+
+	var count int64
+	if err := conn.Model(&Message{}).Where("sent_at IS NULL").Count(&count).Error; err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	return count
+}
+
+func TestAddFollowsTheBusinessTransaction(t *testing.T) {
+	conn := openTestDB(t)
+	ctx := context.Background()
+
+	err := db.WithTx(ctx, conn, func(tx *gorm.DB) error {
+		if err := tx.Create(&order{Total: 10}).Error; err != nil {
+			return err
+		}
+		return Add(tx, "orders.created", map[string]int{"total": 10})
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+
+	rollback := errors.New("rollback")
+	err = db.WithTx(ctx, conn, func(tx *gorm.DB) error {
+		if err := Add(tx, "orders.created", []byte(`{"total":20}`)); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("expected rollback, got %v", err)
+	}
+
+	var messages []Message
+	if err := conn.Find(&messages).Error; err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(messages) != 1 || string(messages[0].Payload) != `{"total":10}` {
+		t.Fatalf("unexpected outbox content: %+v", messages)
+	}
+
+	if err := Add(conn, "", nil); err == nil {
+		t.Fatalf("expected empty topic error")
+	}
+}
+
+func TestDispatchOncePublishesAndRetries(t *testing.T) {
+	conn := openTestDB(t)
+	ctx := context.Background()
+
+	for _, topic := range []string{"a", "b", "c"} {
+		if err := Add(conn, topic, topic); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	publisher := &MemoryPublisher{Fail: func(message Message) error {
+		if message.Topic == "b" {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	}}
+	dispatcher, err := NewDispatcher(conn, publisher, Config{InitialBackoff: time.Hour, MaxBackoff: 2 * time.Hour})
+	if err != nil {
+		t.Fatalf("NewDispatcher: %v", err)
+	}
+
+	processed, err := dispatcher.DispatchOnce(ctx)
+	if err != nil || processed != 3 {
+		t.Fatalf("DispatchOnce = %d (%v)", processed, err)
+	}
+	if got := publisher.Messages(); len(got) != 2 || got[0].Topic != "a" || got[1].Topic != "c" {
+		t.Fatalf("unexpected published messages: %+v", got)
+	}
+
+	var failed Message
+	if err := conn.Where("topic = ?", "b").First(&failed).Error; err != nil {
+		t.Fatalf("First: %v", err)
+	}
+	if failed.SentAt != nil || failed.Attempts != 1 || failed.LastError != "broker unavailable" || !failed.AvailableAt.After(time.Now().Add(30*time.Minute)) {
+		t.Fatalf("unexpected failed message state: %+v", failed)
+	}
+
+	processed, err = dispatcher.DispatchOnce(ctx)
+	if err != nil || processed != 0 {
+		t.Fatalf("message in backoff should not be retried yet: %d (%v)", processed, err)
+	}
+
+	publisher.Fail = nil
+	if err := conn.Model(&Message{}).Where("id = ?", failed.ID).Update("available_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if processed, err := dispatcher.DispatchOnce(ctx); err != nil || processed != 1 {
+		t.Fatalf("retry = %d (%v)", processed, err)
+	}
+	if pending := countPending(t, conn); pending != 0 {
+		t.Fatalf("pending = %d", pending)
+	}
+}
+
+func TestRunStopsGracefullyAndCleansUp(t *testing.T) {
+	conn := openTestDB(t)
+	if err := Add(conn, "a", "payload"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	publisher := &MemoryPublisher{}
+	dispatcher, err := NewDispatcher(conn, publisher, Config{PollInterval: 10 * time.Millisecond, Retention: time.Hour})
+	if err != nil {
+		t.Fatalf("NewDispatcher: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- dispatcher.Run(ctx) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(publisher.Messages()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected Run to return the context error, got %v", err)
+	}
+	if len(publisher.Messages()) != 1 || countPending(t, conn) != 0 {
+		t.Fatalf("expected the message to be published and marked as sent")
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := conn.Model(&Message{}).Where("sent_at IS NOT NULL").Update("sent_at", &old).Error; err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	removed, err := dispatcher.Cleanup(context.Background())
+	if err != nil || removed != 1 {
+		t.Fatalf("Cleanup = %d (%v)", removed, err)
+	}
+}
+
+func TestDispatchOnceBoundsPublish(t *testing.T) {
+	conn := openTestDB(t)
+	if err := Add(conn, "a", "payload"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	hung := PublisherFunc(func(ctx context.Context, _ Message) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	dispatcher, err := NewDispatcher(conn, hung, Config{PublishTimeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewDispatcher: %v", err)
+	}
+
+	if processed, err := dispatcher.DispatchOnce(context.Background()); err != nil || processed != 1 {
+		t.Fatalf("DispatchOnce = %d (%v)", processed, err)
+	}
+	var failed Message
+	if err := conn.First(&failed).Error; err != nil {
+		t.Fatalf("First: %v", err)
+	}
+	if failed.SentAt != nil || failed.Attempts != 1 || failed.LastError != context.DeadlineExceeded.Error() {
+		t.Fatalf("expected the hung publish to fail with a timeout: %+v", failed)
+	}
+}
+
+func TestDispatchOncePublishesOutsideTheClaim(t *testing.T) {
+	conn := openTestDB(t)
+	if err := Add(conn, "a", "payload"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	// The in-memory database has a single connection: the query below would wait for the
+	// claim transaction until PublishTimeout if Publish still ran inside it.
+	var claimed Message
+	reader := PublisherFunc(func(ctx context.Context, message Message) error {
+		return conn.WithContext(ctx).First(&claimed, message.ID).Error
+	})
+	dispatcher, err := NewDispatcher(conn, reader, Config{PublishTimeout: time.Minute})
+	if err != nil {
+		t.Fatalf("NewDispatcher: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if processed, err := dispatcher.DispatchOnce(ctx); err != nil || processed != 1 {
+		t.Fatalf("DispatchOnce = %d (%v)", processed, err)
+	}
+	if !claimed.AvailableAt.After(time.Now().Add(30 * time.Second)) {
+		t.Fatalf("expected the message to be leased while publishing: %+v", claimed)
+	}
+	if pending := countPending(t, conn); pending != 0 {
+		t.Fatalf("pending = %d", pending)
+	}
+}
+
+func TestTruncateKeepsRunes(t *testing.T) {
+	if got := truncate("añb", 2); got != "a" {
+		t.Fatalf("truncate = %q", got)
+	}
+	if got := truncate("añb", 3); got != "añ" {
+		t.Fatalf("truncate = %q", got)
+	}
+}
+
+func TestNewDispatcherValidatesArguments(t *testing.T) {
+	conn := openTestDB(t)
+	if _, err := NewDispatcher(nil, &MemoryPublisher{}, Config{}); err == nil {
+		t.Fatalf("expected nil db error")
+	}
+	if _, err := NewDispatcher(conn, nil, Config{}); err == nil {
+		t.Fatalf("expected nil publisher error")
+	}
+}
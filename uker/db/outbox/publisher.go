@@ -0,0 +1,49 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+)
+
+// Publisher delivers outbox messages to a broker. A message is marked as sent once Publish
+// returns nil, so delivery is at least once and consumers should deduplicate by Message.ID.
+type Publisher interface {
+	Publish(ctx context.Context, message Message) error
+}
+
+// PublisherFunc adapts a function to the Publisher interface.
+type PublisherFunc func(ctx context.Context, message Message) error
+
+// Publish calls f.
+func (f PublisherFunc) Publish(ctx context.Context, message Message) error {
+	return f(ctx, message)
+}
+
+// MemoryPublisher keeps published messages in memory. It is meant for tests.
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	messages []Message
+	// Fail, when set, is called before storing each message and its error is returned.
+	Fail func(message Message) error
+}
+
+// Publish stores message unless Fail returns an error.
+func (p *MemoryPublisher) Publish(ctx context.Context, message Message) error {
+	if p.Fail != nil {
+		if err := p.Fail(message); err != nil {
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, message)
+	return nil
+}
+
+// Messages returns a copy of the published messages in delivery order.
+func (p *MemoryPublisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message(nil), p.messages...)
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var errNilDB = errors.New("db: nil db")
+
+// PoolConfig tunes the database/sql connection pool. Zero values keep the driver defaults.
+type PoolConfig struct {
+	MaxOpenConns    int           `config:"max_open_conns" desc:"Maximum number of open connections" uker:"min=0"`
+	MaxIdleConns    int           `config:"max_idle_conns" desc:"Maximum number of idle connections" uker:"min=0"`
+	ConnMaxLifetime time.Duration `config:"conn_max_lifetime" desc:"Maximum time a connection may be reused" uker:"min=0s"`
+	ConnMaxIdleTime time.Duration `config:"conn_max_idle_time" desc:"Maximum time a connection may stay idle" uker:"min=0s"`
+}
+
+func (p PoolConfig) apply(sqlDB *sql.DB) {
+	if p.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(p.MaxIdleConns)
+	}
+	if p.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+	if p.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+	}
+}
+
+// RetryConfig controls how Connector.OpenContext retries while the database is not reachable
+// yet, e.g. when MySQL is still booting next to the service.
+type RetryConfig struct {
+	// Attempts is the maximum number of connection attempts. Zero or one disables retries.
+	Attempts int `config:"attempts" default:"1" desc:"Connection attempts on startup" uker:"min=0"`
+	// InitialBackoff is the wait after the first failed attempt; it doubles on each retry.
+	InitialBackoff time.Duration `config:"initial_backoff" default:"500ms" desc:"Wait after the first failed attempt" uker:"min=0s"`
+	// MaxBackoff caps the wait between attempts.
+	MaxBackoff time.Duration `config:"max_backoff" default:"10s" desc:"Maximum wait between attempts" uker:"min=0s"`
+}
+
+// Ping verifies the connection is alive. It is meant for health-check endpoints.
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := sqlDB(db)
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Stats returns the connection pool statistics (open, in use and idle connections, wait
+// count and duration) for monitoring.
+func Stats(db *gorm.DB) (sql.DBStats, error) {
+	sqlDB, err := sqlDB(db)
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+	return sqlDB.Stats(), nil
+}
+
+// Close closes the connection pool of db and of the read replicas attached to it.
+func Close(db *gorm.DB) error {
+	if db == nil {
+		return errNilDB
+	}
+
+	var replicaErr error
+	if plugin, ok := db.Config.Plugins[replicasPluginName].(*replicaResolver); ok {
+		replicaErr = plugin.close()
+	}
+	if err := closeDB(db); err != nil {
+		return err
+	}
+	return replicaErr
+}
+
+func closeDB(db *gorm.DB) error {
+	sqlDB, err := sqlDB(db)
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+func sqlDB(db *gorm.DB) (*sql.DB, error) {
+	if db == nil {
+		return nil, errNilDB
+	}
+	return db.DB()
+}
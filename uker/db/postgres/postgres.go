@@ -0,0 +1,63 @@
+// Package postgres implements the db.Dialect of PostgreSQL, kept apart from package db so
+// only programs connecting to PostgreSQL link its driver.
+package postgres
+
+import (
+	"net"
+	"net/url"
+
+	"github.com/unknowns24/uker/uker/db"
+	postgresDriver "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// ConnData holds the connection data used to connect with PostgreSQL.
+type ConnData struct {
+	Host     string        `config:"host,required" desc:"PostgreSQL server host"`
+	Port     string        `config:"port" default:"5432" desc:"PostgreSQL server port" uker:"port"`
+	Database string        `config:"database,required" desc:"Database name"`
+	User     string        `config:"user,required" desc:"Database user"`
+	Password string        `config:"password,secret" desc:"Database password"`
+	SSLMode  string        `config:"sslmode" default:"disable" desc:"libpq sslmode" uker:"oneof=disable allow prefer require verify-ca verify-full"`
+	Pool     db.PoolConfig `config:"pool"`
+}
+
+// New creates a new Connector instance for PostgreSQL.
+func New(conn ConnData, opts ...db.Option) db.Connector {
+	return db.New(conn, opts...)
+}
+
+// Driver implements db.Dialect.
+func (c ConnData) Driver() db.Driver {
+	return db.DriverPostgres
+}
+
+// DSN implements db.Dialect. The connection string uses the URL form so credentials are
+// escaped.
+func (c ConnData) DSN() (string, error) {
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(c.User, c.Password),
+		Host:   net.JoinHostPort(c.Host, c.Port),
+		Path:   "/" + c.Database,
+	}
+
+	if c.SSLMode != "" {
+		dsn.RawQuery = url.Values{"sslmode": []string{c.SSLMode}}.Encode()
+	}
+	return dsn.String(), nil
+}
+
+// PoolConfig implements db.Dialect.
+func (c ConnData) PoolConfig() db.PoolConfig {
+	return c.Pool
+}
+
+// Dialector implements db.Dialect.
+func (c ConnData) Dialector() (gorm.Dialector, error) {
+	dsn, err := c.DSN()
+	if err != nil {
+		return nil, err
+	}
+	return postgresDriver.Open(dsn), nil
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/unknowns24/uker/uker/db"
+)
+
+func TestPostgresDSNEscapesCredentials(t *testing.T) {
+	dsn, err := ConnData{
+		Host:     "db",
+		Port:     "5432",
+		Database: "app",
+		User:     "svc",
+		Password: "p@ss/word",
+		SSLMode:  "require",
+	}.DSN()
+	if err != nil {
+		t.Fatalf("DSN: %v", err)
+	}
+
+	if dsn != "postgres://svc:p%40ss%2Fword@db:5432/app?sslmode=require" {
+		t.Fatalf("DSN = %s", dsn)
+	}
+}
+
+func TestOpenContextRetriesUntilDeadline(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	connector := New(ConnData{Host: "127.0.0.1", Port: port, Database: "app", User: "app", SSLMode: "disable"},
+		db.WithRetry(db.RetryConfig{Attempts: 100, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}))
+
+	started := time.Now()
+	_, err = connector.OpenContext(ctx)
+	if err == nil {
+		t.Fatalf("expected error for unreachable database")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("retries ignored the deadline: %s", elapsed)
+	}
+}
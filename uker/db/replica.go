@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// ReplicaPolicy selects the replica serving each read.
+type ReplicaPolicy string
+
+const (
+	// ReplicaRoundRobin cycles through the replicas in order. It is the default.
+	ReplicaRoundRobin ReplicaPolicy = "round_robin"
+	// ReplicaRandom picks a replica at random for every read.
+	ReplicaRandom ReplicaPolicy = "random"
+)
+
+const replicasPluginName = "uker:replicas"
+
+// WithReplicas routes reads to the given read replicas using policy, while writes, raw
+// statements, locking reads, schema introspection and transactions stay on the primary. The
+// helpers of this module keep their own bookkeeping on the primary; run AutoMigrate with a
+// UsePrimary context so its column checks do too. Every replica is opened with its own pool
+// settings and the Connector retry configuration.
+func WithReplicas(policy ReplicaPolicy, replicas ...Dialect) Option {
+	return func(c *Connector) {
+		c.policy = policy
+		c.replicas = append(c.replicas, replicas...)
+	}
+}
+
+type usePrimaryKey struct{}
+
+// UsePrimary forces the queries run with the returned context to the primary, e.g. to read a
+// row right after writing it without waiting for replication.
+func UsePrimary(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, usePrimaryKey{}, true)
+}
+
+func usesPrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	forced, _ := ctx.Value(usePrimaryKey{}).(bool)
+	return forced
+}
+
+// replicaResolver is a gorm plugin swapping the connection pool of read statements.
+type replicaResolver struct {
+	primary  gorm.ConnPool
+	replicas []gorm.ConnPool
+	closers  []*gorm.DB
+	policy   ReplicaPolicy
+	next     atomic.Uint64
+}
+
+func (c Connector) openReplicas(ctx context.Context, primary *gorm.DB) error {
+	policy := c.policy
+	if policy == "" {
+		policy = ReplicaRoundRobin
+	}
+	if policy != ReplicaRoundRobin && policy != ReplicaRandom {
+		return fmt.Errorf("db: unknown replica policy %q", policy)
+	}
+
+	resolver := &replicaResolver{policy: policy}
+	for i, dialect := range c.replicas {
+		if dialect == nil {
+			resolver.close()
+			return errNilDialect
+		}
+
+		replica, err := c.connect(ctx, dialect)
+		if err != nil {
+			resolver.close()
+			return fmt.Errorf("db: replica %d: %w", i, err)
+		}
+		resolver.replicas = append(resolver.replicas, replica.Statement.ConnPool)
+		resolver.closers = append(resolver.closers, replica)
+	}
+
+	if err := primary.Use(resolver); err != nil {
+		resolver.close()
+		return err
+	}
+	return nil
+}
+
+func (r *replicaResolver) Name() string {
+	return replicasPluginName
+}
+
+func (r *replicaResolver) Initialize(db *gorm.DB) error {
+	r.primary = db.Statement.ConnPool
+
+	if err := db.Callback().Query().Before("gorm:query").Register(replicasPluginName, r.route); err != nil {
+		return err
+	}
+	return db.Callback().Row().Before("gorm:row").Register(replicasPluginName, r.route)
+}
+
+// route sends the statement to a replica unless it already runs on something other than the
+// primary pool (a transaction or a custom session), locks rows, is raw SQL other than a
+// SELECT, reads the schema catalog, as gorm's Migrator does, or its context asks for the
+// primary.
+func (r *replicaResolver) route(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.ConnPool != r.primary || usesPrimary(stmt.Context) {
+		return
+	}
+	if _, locking := stmt.Clauses["FOR"]; locking {
+		return
+	}
+	if stmt.SQL.Len() > 0 && (!isSelect(stmt.SQL.String()) || readsCatalog(stmt.SQL.String())) {
+		return
+	}
+
+	stmt.ConnPool = r.pick()
+}
+
+func (r *replicaResolver) pick() gorm.ConnPool {
+	if r.policy == ReplicaRandom {
+		return r.replicas[rand.IntN(len(r.replicas))]
+	}
+	return r.replicas[(r.next.Add(1)-1)%uint64(len(r.replicas))]
+}
+
+func (r *replicaResolver) close() error {
+	var firstErr error
+	for _, replica := range r.closers {
+		if err := closeDB(replica); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// catalogPattern matches the schema catalogs queried by gorm's Migrator, e.g. by HasTable: a
+// replica lagging behind a migration would report the schema it had before.
+var catalogPattern = regexp.MustCompile(`(?i)\b(information_schema|sqlite_master|sqlite_schema|pg_catalog|pg_[a-z_]+)\b`)
+
+func readsCatalog(query string) bool {
+	return catalogPattern.MatchString(query)
+}
+
+func isSelect(query string) bool {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	return len(query) >= 6 && strings.EqualFold(query[:6], "select")
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func seedSQLite(t *testing.T, path, name string) sqliteDialect {
+	t.Helper()
+
+	data := sqliteDialect{Path: path}
+	conn, err := newSQLite(data).Open(&widget{})
+	if err != nil {
+		t.Fatalf("Open %s: %v", path, err)
+	}
+	if err := conn.Create(&widget{Name: name}).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := Close(conn); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return data
+}
+
+func firstName(t *testing.T, conn *gorm.DB) string {
+	t.Helper()
+
+	var name string
+	if err := conn.Model(&widget{}).Order("id").Limit(1).Pluck("name", &name).Error; err != nil {
+		t.Fatalf("Pluck: %v", err)
+	}
+	return name
+}
+
+func TestReplicasServeReads(t *testing.T) {
+	dir := t.TempDir()
+	primary := seedSQLite(t, filepath.Join(dir, "primary.db"), "primary")
+	first := seedSQLite(t, filepath.Join(dir, "replica1.db"), "replica-1")
+	second := seedSQLite(t, filepath.Join(dir, "replica2.db"), "replica-2")
+
+	conn, err := newSQLite(primary, WithReplicas(ReplicaRoundRobin, first, second)).Open()
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer Close(conn)
+
+	if got := []string{firstName(t, conn), firstName(t, conn), firstName(t, conn)}; got[0] != "replica-1" || got[1] != "replica-2" || got[2] != "replica-1" {
+		t.Fatalf("round robin reads = %v", got)
+	}
+
+	var raw string
+	if err := conn.Raw("SELECT name FROM widgets LIMIT 1").Scan(&raw).Error; err != nil || raw != "replica-2" {
+		t.Fatalf("raw select = %q (%v)", raw, err)
+	}
+
+	ctx := UsePrimary(context.Background())
+	if name := firstName(t, conn.WithContext(ctx)); name != "primary" {
+		t.Fatalf("UsePrimary read = %q", name)
+	}
+
+	if err := conn.Create(&widget{Name: "written"}).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	var count int64
+	if err := conn.WithContext(ctx).Model(&widget{}).Count(&count).Error; err != nil || count != 2 {
+		t.Fatalf("primary count = %d (%v)", count, err)
+	}
+
+	err = WithTx(context.Background(), conn, func(tx *gorm.DB) error {
+		if name := firstName(t, tx); name != "primary" {
+			t.Errorf("transaction read = %q", name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+}
+
+func TestSchemaIntrospectionStaysOnPrimary(t *testing.T) {
+	dir := t.TempDir()
+	primary := seedSQLite(t, filepath.Join(dir, "primary.db"), "primary")
+	lagging := sqliteDialect{Path: filepath.Join(dir, "replica.db")}
+
+	conn, err := newSQLite(primary, WithReplicas(ReplicaRoundRobin, lagging)).Open()
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer Close(conn)
+
+	if !conn.Migrator().HasTable(&widget{}) {
+		t.Fatalf("HasTable should read the primary schema, not the lagging replica")
+	}
+}
+
+func TestReplicasRejectUnknownPolicy(t *testing.T) {
+	replica := sqliteDialect{Path: sqliteMemory}
+	if _, err := newSQLite(sqliteDialect{Path: sqliteMemory}, WithReplicas("weighted", replica)).Open(); err == nil {
+		t.Fatalf("expected unknown policy error")
+	}
+}
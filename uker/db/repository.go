@@ -0,0 +1,236 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/unknowns24/uker/uker/pagination"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errMissingCursorSecret = errors.New("db: missing cursor secret")
+
+// Scope narrows a query, e.g. to the rows of one business. It matches gorm's Scopes.
+type Scope = func(*gorm.DB) *gorm.DB
+
+// CountStrategy decides how List computes the total number of matching rows.
+type CountStrategy int
+
+const (
+	// CountExact runs a COUNT query on every page. It is the default.
+	CountExact CountStrategy = iota
+	// CountFirstPage only counts when no cursor is given; later pages report a zero total so
+	// clients keep the one received with the first page.
+	CountFirstPage
+	// CountNone never counts, for tables where COUNT is too expensive.
+	CountNone
+)
+
+// RepositoryOption configures a Repository.
+type RepositoryOption func(*repositoryConfig)
+
+type repositoryConfig struct {
+	scopes       []Scope
+	sortFields   []string
+	filterFields []string
+	count        CountStrategy
+}
+
+// WithScopes applies scopes to every read, update and delete of the repository, e.g. to
+// hide archived rows.
+func WithScopes(scopes ...Scope) RepositoryOption {
+	return func(c *repositoryConfig) {
+		c.scopes = append(c.scopes, scopes...)
+	}
+}
+
+// WithSortFields restricts the fields List accepts in the sort parameter. The default "id"
+// tie-breaker is always allowed.
+func WithSortFields(fields ...string) RepositoryOption {
+	return func(c *repositoryConfig) {
+		c.sortFields = append(c.sortFields, fields...)
+	}
+}
+
+// WithFilterFields restricts the fields List accepts in filters.
+func WithFilterFields(fields ...string) RepositoryOption {
+	return func(c *repositoryConfig) {
+		c.filterFields = append(c.filterFields, fields...)
+	}
+}
+
+// WithCount selects how List computes totals.
+func WithCount(strategy CountStrategy) RepositoryOption {
+	return func(c *repositoryConfig) {
+		c.count = strategy
+	}
+}
+
+// Repository implements the CRUD operations and signed cursor listing shared by most
+// entities. Every method joins the transaction carried by its context (see WithTx).
+type Repository[T any] struct {
+	db           *gorm.DB
+	cursorSecret []byte
+	cursorTTL    time.Duration
+	config       repositoryConfig
+}
+
+// NewRepository creates a Repository for T. The secret signs the pagination cursors, which
+// expire after ttl (zero disables expiry).
+func NewRepository[T any](gdb *gorm.DB, secret []byte, ttl time.Duration, opts ...RepositoryOption) (*Repository[T], error) {
+	if gdb == nil {
+		return nil, errNilDB
+	}
+	if len(secret) == 0 {
+		return nil, errMissingCursorSecret
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	repo := &Repository[T]{db: gdb, cursorSecret: append([]byte(nil), secret...), cursorTTL: ttl}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&repo.config)
+		}
+	}
+	if len(repo.config.sortFields) > 0 {
+		repo.config.sortFields = append(repo.config.sortFields, "id")
+	}
+	return repo, nil
+}
+
+// Get returns the entity with the given primary key or gorm.ErrRecordNotFound.
+func (r *Repository[T]) Get(ctx context.Context, id any) (*T, error) {
+	var entity T
+	if err := r.scoped(ctx).Where(primaryKeyIs(id)).Take(&entity).Error; err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// Create inserts entity, filling its primary key and timestamps.
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	return Conn(ctx, r.db).Create(entity).Error
+}
+
+// Update writes every field of entity, including zero values, and returns
+// gorm.ErrRecordNotFound when no row in scope matches its primary key. When T embeds
+// Versioned the update goes through UpdateVersioned and fails with a *ConflictError instead.
+func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
+	if versioned, ok := any(entity).(VersionedModel); ok {
+		return updateVersioned(r.scoped(ctx), versioned)
+	}
+
+	result := r.scoped(ctx).Model(entity).Select("*").Updates(entity)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return r.mustExist(ctx, result.Statement, entity)
+	}
+	return nil
+}
+
+// mustExist tells an update that matched no row from one that changed nothing: MySQL reports
+// changed rather than matched rows unless clientFoundRows is set, so writing the stored
+// values back affects no row.
+func (r *Repository[T]) mustExist(ctx context.Context, stmt *gorm.Statement, entity *T) error {
+	field := stmt.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return gorm.ErrRecordNotFound
+	}
+	id, zero := field.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+	if zero {
+		return gorm.ErrRecordNotFound
+	}
+
+	var count int64
+	if err := r.scoped(UsePrimary(ctx)).Model(new(T)).Where(primaryKeyIs(id)).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete removes the entity with the given primary key, softly when T embeds
+// gorm.DeletedAt, and returns gorm.ErrRecordNotFound when no row in scope matches.
+func (r *Repository[T]) Delete(ctx context.Context, id any) error {
+	result := r.scoped(ctx).Where(primaryKeyIs(id)).Delete(new(T))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindBy returns every entity matching the condition, written as for gorm's Where.
+func (r *Repository[T]) FindBy(ctx context.Context, query any, args ...any) ([]T, error) {
+	var entities []T
+	if err := r.scoped(ctx).Where(query, args...).Find(&entities).Error; err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// List returns one page of entities following the signed cursor contract of the pagination
+// package. The scopes apply on top of the repository ones, e.g. to restrict by business.
+func (r *Repository[T]) List(ctx context.Context, raw url.Values, scopes ...Scope) (*pagination.PagingResponse[T], error) {
+	params, err := pagination.ParseWithSecurity(raw, r.cursorSecret, r.cursorTTL)
+	if err != nil {
+		return nil, err
+	}
+	if err := pagination.ValidateFields(params, r.config.sortFields, r.config.filterFields); err != nil {
+		return nil, err
+	}
+
+	base := r.scoped(ctx).Scopes(scopes...).Session(&gorm.Session{})
+
+	var total int64
+	if r.config.count == CountExact || (r.config.count == CountFirstPage && params.Cursor == nil) {
+		countQuery, err := pagination.ApplyFilters(base, params.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if err := countQuery.Count(&total).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	query, err := pagination.Apply(base, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []T
+	if err := query.Find(&results).Error; err != nil {
+		return nil, err
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = pagination.DefaultLimit
+	}
+
+	page, err := pagination.BuildPageSigned[T](params, results, limit, total, nil, r.cursorSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r *Repository[T]) scoped(ctx context.Context) *gorm.DB {
+	return Conn(ctx, r.db).Model(new(T)).Scopes(r.config.scopes...)
+}
+
+func primaryKeyIs(id any) clause.Expression {
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: clause.PrimaryKey}, Value: id}
+}
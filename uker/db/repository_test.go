@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/unknowns24/uker/uker/pagination"
+	"gorm.io/gorm"
+)
+
+type note struct {
+	ID         uint
+	BusinessID string
+	Title      string
+	Archived   bool
+	CreatedAt  time.Time
+	DeletedAt  gorm.DeletedAt
+}
+
+var repoSecret = []byte("repo-secret")
+
+func notArchived(tx *gorm.DB) *gorm.DB {
+	return tx.Where("archived = ?", false)
+}
+
+func business(id string) Scope {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("business_id = ?", id)
+	}
+}
+
+func newNoteRepo(t *testing.T, opts ...RepositoryOption) *Repository[note] {
+	t.Helper()
+
+	conn := openTestDB(t, sqliteMemory, nil, &note{})
+	repo, err := NewRepository[note](conn, repoSecret, time.Hour, append([]RepositoryOption{WithScopes(notArchived)}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewRepository: %v", err)
+	}
+	return repo
+}
+
+func TestRepositoryCRUD(t *testing.T) {
+	ctx := context.Background()
+	repo := newNoteRepo(t)
+
+	first := &note{BusinessID: "biz-1", Title: "first"}
+	if err := repo.Create(ctx, first); err != nil || first.ID == 0 {
+		t.Fatalf("Create: id=%d err=%v", first.ID, err)
+	}
+	archived := &note{BusinessID: "biz-1", Title: "old", Archived: true}
+	if err := repo.Create(ctx, archived); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := repo.Get(ctx, archived.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("base scope should hide archived notes, got %v", err)
+	}
+
+	first.Title = ""
+	if err := repo.Update(ctx, first); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err := repo.Get(ctx, first.ID)
+	if err != nil || got.Title != "" {
+		t.Fatalf("Get after update = %+v (%v)", got, err)
+	}
+	if err := repo.Update(ctx, archived); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("Update out of scope should fail, got %v", err)
+	}
+
+	found, err := repo.FindBy(ctx, "business_id = ?", "biz-1")
+	if err != nil || len(found) != 1 {
+		t.Fatalf("FindBy = %d notes (%v)", len(found), err)
+	}
+
+	if err := repo.Delete(ctx, first.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := repo.Delete(ctx, first.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("second Delete should fail, got %v", err)
+	}
+}
+
+func TestRepositoryNoOpUpdate(t *testing.T) {
+	ctx := context.Background()
+	repo := newNoteRepo(t)
+
+	// MySQL without clientFoundRows counts changed rather than matched rows.
+	if err := repo.db.Callback().Update().After("gorm:update").Register("test:changed_rows", func(tx *gorm.DB) {
+		tx.RowsAffected = 0
+	}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	stored := &note{BusinessID: "biz-1", Title: "same"}
+	if err := repo.Create(ctx, stored); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	archived := &note{BusinessID: "biz-1", Title: "old", Archived: true}
+	if err := repo.Create(ctx, archived); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := repo.Update(ctx, stored); err != nil {
+		t.Fatalf("an update changing nothing should succeed, got %v", err)
+	}
+	if err := repo.Update(ctx, archived); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("Update out of scope should fail, got %v", err)
+	}
+	if err := repo.Update(ctx, &note{ID: 999, BusinessID: "biz-1"}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("Update of a missing row should fail, got %v", err)
+	}
+}
+
+func TestRepositoryListPaginatesWithSignedCursors(t *testing.T) {
+	ctx := context.Background()
+	repo := newNoteRepo(t, WithSortFields("title"), WithFilterFields("title"))
+
+	for i := 4; i >= 0; i-- {
+		if err := repo.Create(ctx, &note{BusinessID: "biz-1", Title: fmt.Sprintf("note-%d", i)}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	if err := repo.Create(ctx, &note{BusinessID: "biz-2", Title: "a-other"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	raw := url.Values{"limit": {"2"}, "sort": {"title:asc"}}
+	page, err := repo.List(ctx, raw, business("biz-1"))
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(page.Data) != 2 || page.Paging.Total != 5 || !page.Paging.HasMore || page.Data[0].Title != "note-0" {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+
+	next, err := repo.List(ctx, url.Values{"cursor": {page.Paging.NextCursor}}, business("biz-1"))
+	if err != nil {
+		t.Fatalf("List next: %v", err)
+	}
+	if len(next.Data) != 2 || next.Data[0].Title != "note-2" {
+		t.Fatalf("unexpected second page: %+v", next.Data)
+	}
+
+	if _, err := repo.List(ctx, url.Values{"sort": {"created_at:asc"}}); !errors.Is(err, pagination.ErrInvalidSort) {
+		t.Fatalf("expected sort allow list error, got %v", err)
+	}
+	if _, err := repo.List(ctx, url.Values{"business_id_eq": {"biz-2"}}); !errors.Is(err, pagination.ErrInvalidFilter) {
+		t.Fatalf("expected filter allow list error, got %v", err)
+	}
+}
+
+func TestRepositoryCountStrategies(t *testing.T) {
+	ctx := context.Background()
+	repo := newNoteRepo(t, WithCount(CountFirstPage))
+
+	for i := 0; i < 3; i++ {
+		if err := repo.Create(ctx, &note{BusinessID: "biz-1", Title: fmt.Sprintf("note-%d", i)}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	page, err := repo.List(ctx, url.Values{"limit": {"1"}})
+	if err != nil || page.Paging.Total != 3 {
+		t.Fatalf("first page total = %d (%v)", page.Paging.Total, err)
+	}
+	next, err := repo.List(ctx, url.Values{"cursor": {page.Paging.NextCursor}})
+	if err != nil || next.Paging.Total != 0 || len(next.Data) != 1 {
+		t.Fatalf("later page should skip count: %+v (%v)", next, err)
+	}
+}
+
+func TestNewRepositoryRequiresSecret(t *testing.T) {
+	conn := openWidgets(t)
+	if _, err := NewRepository[widget](conn, nil, time.Hour); err == nil {
+		t.Fatalf("expected missing secret error")
+	}
+}
@@ -0,0 +1,58 @@
+// Package sqlite implements the db.Dialect of SQLite. It lives apart from package db because
+// its driver requires cgo, so only programs opening SQLite databases link it.
+package sqlite
+
+import (
+	"github.com/unknowns24/uker/uker/db"
+	sqliteDriver "gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Memory opens a private in-memory database.
+const Memory = ":memory:"
+
+// ConnData holds the connection data used to open a SQLite database.
+type ConnData struct {
+	// Path is the database file or Memory. URI parameters such as
+	// "file:test.db?cache=shared" are passed to the driver untouched.
+	Path string        `config:"path" default:":memory:" desc:"SQLite database file"`
+	Pool db.PoolConfig `config:"pool"`
+}
+
+// New creates a new Connector instance for SQLite.
+func New(conn ConnData, opts ...db.Option) db.Connector {
+	return db.New(conn, opts...)
+}
+
+// Driver implements db.Dialect.
+func (c ConnData) Driver() db.Driver {
+	return db.DriverSQLite
+}
+
+// DSN implements db.Dialect.
+func (c ConnData) DSN() (string, error) {
+	if c.Path == "" {
+		return Memory, nil
+	}
+	return c.Path, nil
+}
+
+// PoolConfig implements db.Dialect.
+// Private in-memory databases exist per connection, so the pool is limited to a single
+// connection unless configured otherwise.
+func (c ConnData) PoolConfig() db.PoolConfig {
+	pool := c.Pool
+	if dsn, _ := c.DSN(); dsn == Memory && pool.MaxOpenConns == 0 {
+		pool.MaxOpenConns = 1
+	}
+	return pool
+}
+
+// Dialector implements db.Dialect.
+func (c ConnData) Dialector() (gorm.Dialector, error) {
+	dsn, err := c.DSN()
+	if err != nil {
+		return nil, err
+	}
+	return sqliteDriver.Open(dsn), nil
+}
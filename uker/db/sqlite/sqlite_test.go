@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/unknowns24/uker/uker/db"
+)
+
+type widget struct {
+	ID   uint
+	Name string
+}
+
+func TestOpenSQLiteRunsMigrations(t *testing.T) {
+	conn, err := New(ConnData{Path: Memory}).Open(&widget{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer db.Close(conn)
+
+	if db.DriverOf(conn) != db.DriverSQLite {
+		t.Fatalf("DriverOf = %s", db.DriverOf(conn))
+	}
+	if err := conn.Create(&widget{Name: "gear"}).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	var count int64
+	if err := conn.Model(&widget{}).Count(&count).Error; err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("count = %d", count)
+	}
+}
+
+func TestOpenAppliesPoolAndExposesStats(t *testing.T) {
+	conn, err := New(ConnData{Path: Memory, Pool: db.PoolConfig{MaxIdleConns: 1}}).Open()
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer db.Close(conn)
+
+	if err := db.Ping(context.Background(), conn); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	stats, err := db.Stats(conn)
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.MaxOpenConnections != 1 {
+		t.Fatalf("MaxOpenConnections = %d", stats.MaxOpenConnections)
+	}
+	if stats.OpenConnections < 1 {
+		t.Fatalf("OpenConnections = %d", stats.OpenConnections)
+	}
+}
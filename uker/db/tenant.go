@@ -0,0 +1,261 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const tenantPluginName = "uker:tenant"
+
+var (
+	// ErrMissingTenant is returned for statements on a TenantScoped model run with a context
+	// carrying neither a tenant nor a bypass.
+	ErrMissingTenant = errors.New("db: missing tenant in context")
+	// ErrTenantMismatch is returned when a row being created belongs to another tenant than
+	// the one in the context.
+	ErrTenantMismatch = errors.New("db: row belongs to another tenant")
+)
+
+// TenantScoped is implemented by models whose rows belong to a tenant. TenantColumn returns
+// the column holding the tenant id, e.g. "business_id".
+type TenantScoped interface {
+	TenantColumn() string
+}
+
+// TenantBypass describes a statement run across tenants with WithoutTenantScope.
+type TenantBypass struct {
+	Table     string
+	Operation string
+	Reason    string
+	Actor     string
+}
+
+type tenantKey struct{}
+
+type tenantBypassKey struct{}
+
+// WithTenant returns a copy of ctx scoping the following statements to tenant, usually set by
+// an HTTP middleware from the authenticated user.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant stored with WithTenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// WithoutTenantScope returns a copy of ctx whose statements are not scoped to any tenant, for
+// admin and maintenance jobs working across tenants. The reason is mandatory and is reported,
+// with the actor of the context, to TenantConfig.OnBypass for every statement it affects.
+func WithoutTenantScope(ctx context.Context, reason string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, tenantBypassKey{}, reason)
+}
+
+func tenantBypassReason(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	reason, ok := ctx.Value(tenantBypassKey{}).(string)
+	return reason, ok && reason != ""
+}
+
+// TenantConfig configures the plugin returned by NewTenantPlugin.
+type TenantConfig struct {
+	// OnBypass is called for every statement on a TenantScoped model run with
+	// WithoutTenantScope, e.g. to log it or write it to an audit trail.
+	OnBypass func(ctx context.Context, bypass TenantBypass)
+}
+
+type tenantPlugin struct {
+	config  TenantConfig
+	columns sync.Map // *schema.Schema -> string, empty for models that are not scoped
+}
+
+// NewTenantPlugin returns a GORM plugin, registered with gorm.DB.Use, that scopes queries,
+// updates and deletes on TenantScoped models to the tenant of the context and stamps it on
+// the rows they create. Statements without a tenant fail with ErrMissingTenant unless the
+// context comes from WithoutTenantScope. Raw SQL is never rewritten.
+func NewTenantPlugin(config TenantConfig) gorm.Plugin {
+	return &tenantPlugin{config: config}
+}
+
+func (p *tenantPlugin) Name() string {
+	return tenantPluginName
+}
+
+func (p *tenantPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("gorm:before_create").Register(tenantPluginName+":create", p.beforeCreate); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("gorm:query").Register(tenantPluginName+":query", p.scope("query")); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register(tenantPluginName+":row", p.scope("query")); err != nil {
+		return err
+	}
+	// Updates and deletes are scoped before the model hooks and the audit plugin, which may
+	// build the statement on its own for soft deletes.
+	if err := callbacks.Update().Before("gorm:before_update").Register(tenantPluginName+":update", p.scope("update")); err != nil {
+		return err
+	}
+	return callbacks.Delete().Before("gorm:before_delete").Register(tenantPluginName+":delete", p.scope("delete"))
+}
+
+// column returns the tenant column of the statement model, or "" when it is not scoped.
+func (p *tenantPlugin) column(stmt *gorm.Statement) string {
+	if stmt.Schema == nil {
+		return ""
+	}
+	if cached, ok := p.columns.Load(stmt.Schema); ok {
+		return cached.(string)
+	}
+
+	column := tenantColumn(stmt.Schema)
+	p.columns.Store(stmt.Schema, column)
+	return column
+}
+
+// tenantColumn returns the database name of the tenant column of a TenantScoped model, or ""
+// for other models.
+func tenantColumn(s *schema.Schema) string {
+	scoped, ok := reflect.New(s.ModelType).Interface().(TenantScoped)
+	if !ok {
+		return ""
+	}
+	column := scoped.TenantColumn()
+	if field := s.LookUpField(column); field != nil {
+		column = field.DBName
+	}
+	return column
+}
+
+// tenant returns the tenant the statement is scoped to. It returns false when the statement
+// must run unscoped, after reporting the bypass, or fails.
+func (p *tenantPlugin) tenant(db *gorm.DB, operation string) (string, bool) {
+	ctx := db.Statement.Context
+	if tenant, ok := TenantFromContext(ctx); ok {
+		return tenant, true
+	}
+
+	reason, ok := tenantBypassReason(ctx)
+	if !ok {
+		db.AddError(fmt.Errorf("%w: %s on %s", ErrMissingTenant, operation, db.Statement.Table))
+		return "", false
+	}
+	if p.config.OnBypass != nil {
+		actor, _ := ActorFromContext(ctx)
+		p.config.OnBypass(ctx, TenantBypass{Table: db.Statement.Table, Operation: operation, Reason: reason, Actor: actor})
+	}
+	return "", false
+}
+
+func (p *tenantPlugin) scope(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		stmt := db.Statement
+		if db.Error != nil || stmt.SQL.Len() > 0 {
+			return
+		}
+		column := p.column(stmt)
+		if column == "" {
+			return
+		}
+		if operation != "query" && missingWhere(stmt) {
+			// Leave GORM to reject the global update or delete instead of turning it into
+			// one over the whole tenant.
+			return
+		}
+
+		tenant, ok := p.tenant(db, operation)
+		if !ok {
+			return
+		}
+		stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Value: tenant},
+		}})
+	}
+}
+
+func (p *tenantPlugin) beforeCreate(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil {
+		return
+	}
+	column := p.column(stmt)
+	if column == "" {
+		return
+	}
+	field := stmt.Schema.LookUpField(column)
+	if field == nil {
+		db.AddError(fmt.Errorf("db: tenant column %q not found in %s", column, stmt.Schema.Name))
+		return
+	}
+
+	tenant, ok := p.tenant(db, "create")
+	if !ok {
+		return
+	}
+
+	rv := stmt.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			stampTenant(db, field, reflect.Indirect(rv.Index(i)), tenant)
+		}
+	case reflect.Struct:
+		stampTenant(db, field, rv, tenant)
+	case reflect.Map:
+		stmt.SetColumn(field.DBName, tenant)
+	}
+}
+
+func stampTenant(db *gorm.DB, field *schema.Field, row reflect.Value, tenant string) {
+	ctx := db.Statement.Context
+	if value, zero := field.ValueOf(ctx, row); !zero && fmt.Sprint(value) != tenant {
+		db.AddError(fmt.Errorf("%w: %v", ErrTenantMismatch, value))
+		return
+	}
+	if err := field.Set(ctx, row, tenant); err != nil {
+		db.AddError(err)
+	}
+}
+
+// missingWhere reports whether an update or delete has no condition at all, neither explicit
+// nor from the primary key of its model, and would be rejected by GORM.
+func missingWhere(stmt *gorm.Statement) bool {
+	if stmt.AllowGlobalUpdate {
+		return false
+	}
+	if where, ok := stmt.Clauses["WHERE"]; ok {
+		if expr, ok := where.Expression.(clause.Where); !ok || len(expr.Exprs) > 0 {
+			return false
+		}
+	}
+	if _, values := primaryKeyCondition(stmt, stmt.ReflectValue); len(values) > 0 {
+		return false
+	}
+	if stmt.Model != nil {
+		if _, values := primaryKeyCondition(stmt, reflect.Indirect(reflect.ValueOf(stmt.Model))); len(values) > 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type project struct {
+	ID       uint
+	TenantID string `gorm:"size:64;index"`
+	Name     string
+	AuditFields
+	DeletedAt gorm.DeletedAt
+}
+
+func (project) TenantColumn() string {
+	return "tenant_id"
+}
+
+func openTenanted(t *testing.T, config TenantConfig) *gorm.DB {
+	t.Helper()
+	return openTestDB(t, sqliteMemory, []gorm.Plugin{NewAuditPlugin(AuditConfig{}), NewTenantPlugin(config)}, &project{}, &widget{})
+}
+
+func TestTenantScopesStatements(t *testing.T) {
+	conn := openTenanted(t, TenantConfig{})
+	acme := WithActor(WithTenant(context.Background(), "acme"), "alice")
+	globex := WithTenant(context.Background(), "globex")
+
+	rows := []project{{Name: "rocket"}, {Name: "anvil"}}
+	if err := conn.WithContext(acme).Create(&rows).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	other := &project{Name: "dome"}
+	if err := conn.WithContext(globex).Create(other).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if rows[0].TenantID != "acme" || other.TenantID != "globex" {
+		t.Fatalf("expected creates to be stamped, got %q and %q", rows[0].TenantID, other.TenantID)
+	}
+
+	var visible []project
+	if err := conn.WithContext(acme).Order("id").Find(&visible).Error; err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(visible) != 2 {
+		t.Fatalf("expected the 2 acme projects, got %+v", visible)
+	}
+	var total int64
+	if err := conn.WithContext(globex).Model(&project{}).Count(&total).Error; err != nil || total != 1 {
+		t.Fatalf("Count = %d (%v)", total, err)
+	}
+	if err := conn.WithContext(acme).First(&project{}, other.ID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected another tenant's row to be hidden, got %v", err)
+	}
+
+	if result := conn.WithContext(acme).Model(&project{}).Where("id = ?", other.ID).Update("name", "stolen"); result.Error != nil || result.RowsAffected != 0 {
+		t.Fatalf("update across tenants = %d (%v)", result.RowsAffected, result.Error)
+	}
+	if result := conn.WithContext(acme).Delete(other); result.Error != nil || result.RowsAffected != 0 {
+		t.Fatalf("delete across tenants = %d (%v)", result.RowsAffected, result.Error)
+	}
+	if result := conn.WithContext(acme).Delete(&rows[1]); result.Error != nil || result.RowsAffected != 1 {
+		t.Fatalf("delete = %d (%v)", result.RowsAffected, result.Error)
+	}
+
+	if err := conn.WithContext(acme).Model(&project{}).Update("name", "all").Error; !errors.Is(err, gorm.ErrMissingWhereClause) {
+		t.Fatalf("expected global updates to stay rejected, got %v", err)
+	}
+	if err := conn.WithContext(acme).Create(&project{TenantID: "globex", Name: "spy"}).Error; !errors.Is(err, ErrTenantMismatch) {
+		t.Fatalf("expected ErrTenantMismatch, got %v", err)
+	}
+
+	if err := conn.WithContext(globex).Unscoped().Where("name = ?", "dome").First(&project{}).Error; err != nil {
+		t.Fatalf("expected globex project to survive: %v", err)
+	}
+}
+
+func TestTenantRequiresContext(t *testing.T) {
+	conn := openTenanted(t, TenantConfig{})
+
+	if err := conn.Create(&project{Name: "rocket"}).Error; !errors.Is(err, ErrMissingTenant) {
+		t.Fatalf("expected ErrMissingTenant on create, got %v", err)
+	}
+	if err := conn.Find(&[]project{}).Error; !errors.Is(err, ErrMissingTenant) {
+		t.Fatalf("expected ErrMissingTenant on query, got %v", err)
+	}
+	if err := conn.WithContext(WithoutTenantScope(context.Background(), "")).Find(&[]project{}).Error; !errors.Is(err, ErrMissingTenant) {
+		t.Fatalf("a bypass without reason should not be accepted, got %v", err)
+	}
+	if err := conn.Create(&widget{Name: "plain"}).Error; err != nil {
+		t.Fatalf("models that do not opt in should not be scoped: %v", err)
+	}
+}
+
+func TestTenantBypassIsReported(t *testing.T) {
+	var bypasses []TenantBypass
+	conn := openTenanted(t, TenantConfig{OnBypass: func(_ context.Context, bypass TenantBypass) {
+		bypasses = append(bypasses, bypass)
+	}})
+
+	for _, tenant := range []string{"acme", "globex"} {
+		if err := conn.WithContext(WithTenant(context.Background(), tenant)).Create(&project{Name: tenant}).Error; err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	admin := WithActor(WithoutTenantScope(context.Background(), "nightly report"), "ops")
+	var all []project
+	if err := conn.WithContext(admin).Find(&all).Error; err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("expected both tenants' projects, got %+v", all)
+	}
+
+	want := TenantBypass{Table: "projects", Operation: "query", Reason: "nightly report", Actor: "ops"}
+	if len(bypasses) != 1 || bypasses[0] != want {
+		t.Fatalf("unexpected bypasses %+v", bypasses)
+	}
+}
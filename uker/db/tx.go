@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// MySQL error numbers for transactions aborted by the server and safe to run again.
+const (
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+)
+
+// TxFunc is the unit of work run by WithTx.
+type TxFunc func(tx *gorm.DB) error
+
+// TxOption configures WithTx.
+type TxOption func(*txConfig)
+
+type txConfig struct {
+	retry   RetryConfig
+	options *sql.TxOptions
+}
+
+// WithTxRetry runs the whole function again, with backoff, when the transaction is aborted
+// by a deadlock (MySQL 1213, PostgreSQL 40P01) or a lock wait timeout (MySQL 1205). It only
+// applies to the outermost transaction; the function must be safe to run more than once.
+func WithTxRetry(retry RetryConfig) TxOption {
+	return func(c *txConfig) {
+		c.retry = retry
+	}
+}
+
+// WithTxOptions sets the isolation level and read-only flag of the outermost transaction.
+func WithTxOptions(options *sql.TxOptions) TxOption {
+	return func(c *txConfig) {
+		c.options = options
+	}
+}
+
+// PanicError is returned by WithTx when the function panics. The transaction is rolled back.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("db: panic in transaction: %v", e.Value)
+}
+
+type txKey struct{}
+
+type txState struct {
+	tx    *gorm.DB
+	depth int
+}
+
+// WithTx runs fn inside a transaction that is committed when fn returns nil and rolled back
+// when it returns an error or panics.
+//
+// The transaction travels in the context given to fn through tx.Statement.Context, so code
+// that obtains its handle with Conn joins it without changing signatures. When ctx already
+// carries a transaction, WithTx creates a savepoint instead and only that savepoint is rolled
+// back on failure.
+func WithTx(ctx context.Context, gdb *gorm.DB, fn TxFunc, opts ...TxOption) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return withSavepoint(ctx, state, fn)
+	}
+	if gdb == nil {
+		return errNilDB
+	}
+
+	var cfg txConfig
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := runTx(ctx, gdb, fn, cfg.options)
+		if err == nil || attempt+1 >= cfg.retry.Attempts || !IsRetryable(err) {
+			return err
+		}
+		if err := sleepContext(ctx, Backoff(attempt, cfg.retry.InitialBackoff, cfg.retry.MaxBackoff)); err != nil {
+			return err
+		}
+	}
+}
+
+// TxFromContext returns the transaction carried by ctx, set by WithTx or ContextWithTx.
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	state, ok := ctx.Value(txKey{}).(*txState)
+	if !ok {
+		return nil, false
+	}
+	return state.tx, true
+}
+
+// ContextWithTx returns a copy of ctx carrying tx, so Conn and nested WithTx calls use it. It
+// is meant for transactions managed outside WithTx, such as the per-test ones of dbtest.
+func ContextWithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	state := &txState{}
+	ctx = context.WithValue(ctx, txKey{}, state)
+	state.tx = tx.WithContext(ctx)
+	return ctx
+}
+
+// Conn returns the transaction carried by ctx or, when there is none, gdb bound to ctx.
+// Repositories use it so their methods take part in an outer WithTx transparently.
+func Conn(ctx context.Context, gdb *gorm.DB) *gorm.DB {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	if gdb == nil || ctx == nil {
+		return gdb
+	}
+	return gdb.WithContext(ctx)
+}
+
+// IsRetryable reports whether err aborted a transaction that can be run again: a deadlock or
+// lock wait timeout on MySQL, or a deadlock or serialization failure on PostgreSQL.
+func IsRetryable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrDeadlock || mysqlErr.Number == mysqlErrLockWaitTimeout
+	}
+
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		code := stateErr.SQLState()
+		return code == "40P01" || code == "40001"
+	}
+	return false
+}
+
+func runTx(ctx context.Context, gdb *gorm.DB, fn TxFunc, options *sql.TxOptions) (err error) {
+	var opts []*sql.TxOptions
+	if options != nil {
+		opts = append(opts, options)
+	}
+
+	tx := gdb.WithContext(ctx).Begin(opts...)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	state := &txState{tx: tx}
+	state.tx = tx.WithContext(context.WithValue(ctx, txKey{}, state))
+
+	committed := false
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	if err = fn(state.tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+func withSavepoint(ctx context.Context, parent *txState, fn TxFunc) (err error) {
+	state := &txState{depth: parent.depth + 1}
+	name := "uker_sp_" + strconv.Itoa(state.depth)
+	state.tx = parent.tx.WithContext(context.WithValue(ctx, txKey{}, state))
+
+	if err := state.tx.SavePoint(name).Error; err != nil {
+		return err
+	}
+
+	released := false
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+		if !released {
+			state.tx.RollbackTo(name)
+		}
+	}()
+
+	if err = fn(state.tx); err != nil {
+		return err
+	}
+
+	if err = state.tx.Exec("RELEASE SAVEPOINT " + name).Error; err != nil {
+		return err
+	}
+	released = true
+	return nil
+}
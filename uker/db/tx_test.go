@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+func openWidgets(t *testing.T) *gorm.DB {
+	t.Helper()
+	return openTestDB(t, sqliteMemory, nil, &widget{})
+}
+
+func countWidgets(t *testing.T, conn *gorm.DB) int64 {
+	t.Helper()
+
+	var count int64
+	if err := conn.Model(&widget{}).Count(&count).Error; err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	return count
+}
+
+func TestWithTxCommitsAndRollsBack(t *testing.T) {
+	conn := openWidgets(t)
+	ctx := context.Background()
+
+	if err := WithTx(ctx, conn, func(tx *gorm.DB) error {
+		return tx.Create(&widget{Name: "kept"}).Error
+	}); err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+
+	failure := errors.New("boom")
+	err := WithTx(ctx, conn, func(tx *gorm.DB) error {
+		if err := tx.Create(&widget{Name: "discarded"}).Error; err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected fn error, got %v", err)
+	}
+
+	if count := countWidgets(t, conn); count != 1 {
+		t.Fatalf("count = %d", count)
+	}
+}
+
+func TestWithTxRecoversPanics(t *testing.T) {
+	conn := openWidgets(t)
+
+	err := WithTx(context.Background(), conn, func(tx *gorm.DB) error {
+		tx.Create(&widget{Name: "discarded"})
+		panic("unexpected")
+	})
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "unexpected" {
+		t.Fatalf("expected PanicError, got %v", err)
+	}
+	if count := countWidgets(t, conn); count != 0 {
+		t.Fatalf("count = %d", count)
+	}
+}
+
+func TestWithTxNestedUsesSavepoints(t *testing.T) {
+	conn := openWidgets(t)
+
+	err := WithTx(context.Background(), conn, func(tx *gorm.DB) error {
+		ctx := tx.Statement.Context
+		if err := Conn(ctx, conn).Create(&widget{Name: "outer"}).Error; err != nil {
+			return err
+		}
+
+		inner := WithTx(ctx, conn, func(tx *gorm.DB) error {
+			if err := tx.Create(&widget{Name: "inner"}).Error; err != nil {
+				return err
+			}
+			return errors.New("inner failed")
+		})
+		if inner == nil {
+			return errors.New("expected inner error")
+		}
+
+		return WithTx(ctx, conn, func(tx *gorm.DB) error {
+			return tx.Create(&widget{Name: "sibling"}).Error
+		})
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+
+	var names []string
+	if err := conn.Model(&widget{}).Order("id").Pluck("name", &names).Error; err != nil {
+		t.Fatalf("Pluck: %v", err)
+	}
+	if fmt.Sprint(names) != "[outer sibling]" {
+		t.Fatalf("names = %v", names)
+	}
+}
+
+func TestWithTxRetriesDeadlocks(t *testing.T) {
+	conn := openWidgets(t)
+	retry := WithTxRetry(RetryConfig{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	calls := 0
+	err := WithTx(context.Background(), conn, func(tx *gorm.DB) error {
+		calls++
+		if err := tx.Create(&widget{Name: "retried"}).Error; err != nil {
+			return err
+		}
+		if calls < 3 {
+			return fmt.Errorf("update: %w", &mysql.MySQLError{Number: mysqlErrDeadlock})
+		}
+		return nil
+	}, retry)
+	if err != nil || calls != 3 {
+		t.Fatalf("calls = %d, err = %v", calls, err)
+	}
+	if count := countWidgets(t, conn); count != 1 {
+		t.Fatalf("count = %d", count)
+	}
+
+	calls = 0
+	err = WithTx(context.Background(), conn, func(tx *gorm.DB) error {
+		calls++
+		return &mysql.MySQLError{Number: 1062}
+	}, retry)
+	if err == nil || calls != 1 {
+		t.Fatalf("non retryable error ran %d times (%v)", calls, err)
+	}
+}
+
+func TestConnWithoutTransaction(t *testing.T) {
+	conn := openWidgets(t)
+
+	if _, ok := TxFromContext(context.Background()); ok {
+		t.Fatalf("unexpected transaction in empty context")
+	}
+	if Conn(context.Background(), conn) == nil {
+		t.Fatalf("expected fallback connection")
+	}
+	if !IsRetryable(&mysql.MySQLError{Number: mysqlErrLockWaitTimeout}) || IsRetryable(errors.New("other")) {
+		t.Fatalf("unexpected IsRetryable result")
+	}
+}
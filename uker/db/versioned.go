@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	liberr "github.com/unknowns24/uker/uker/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ColumnVersion is the optimistic locking column of Versioned.
+const ColumnVersion = "version"
+
+// ErrVersionConflict matches, with errors.Is, every ConflictError.
+var ErrVersionConflict = errors.New("db: version conflict")
+
+// Versioned can be embedded in models to detect concurrent edits. UpdateVersioned only writes
+// a row when its version is still the one read, and increments it.
+type Versioned struct {
+	Version int64 `gorm:"column:version;not null;default:1" json:"version"`
+}
+
+func (v *Versioned) versioned() *Versioned {
+	return v
+}
+
+// VersionedModel is implemented by pointers to models embedding Versioned.
+type VersionedModel interface {
+	versioned() *Versioned
+}
+
+// ConflictError is returned when an entity was modified or deleted since its version was
+// read. It unwraps to a uker/errors value with CodeConflict, written as 409 by httpx.
+type ConflictError struct {
+	Table   string
+	Version int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("db: %s was modified concurrently, version %d is stale", e.Table, e.Version)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+func (e *ConflictError) Unwrap() error {
+	return liberr.New(liberr.CodeConflict, fmt.Sprintf("%s was modified by someone else, reload it and try again", e.Table))
+}
+
+// UpdateVersioned writes entity, every field or only the given columns, provided its row still
+// has the version held by entity, and increments that version. When no row matches it returns
+// a *ConflictError and leaves entity unchanged. It joins the transaction carried by ctx.
+func UpdateVersioned(ctx context.Context, gdb *gorm.DB, entity VersionedModel, columns ...string) error {
+	if gdb == nil {
+		return errNilDB
+	}
+	return updateVersioned(Conn(ctx, gdb), entity, columns...)
+}
+
+func updateVersioned(tx *gorm.DB, entity VersionedModel, columns ...string) error {
+	version := entity.versioned()
+	expected := version.Version
+
+	query := tx.Model(entity).Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: ColumnVersion}, Value: expected})
+	if len(columns) == 0 {
+		query = query.Select("*")
+	} else {
+		query = query.Select(append(columns[:len(columns):len(columns)], ColumnVersion))
+	}
+
+	version.Version = expected + 1
+	result := query.Updates(entity)
+	if result.Error != nil {
+		version.Version = expected
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		version.Version = expected
+		return &ConflictError{Table: result.Statement.Table, Version: expected}
+	}
+	return nil
+}
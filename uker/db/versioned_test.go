@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	liberr "github.com/unknowns24/uker/uker/errors"
+	"gorm.io/gorm"
+)
+
+type document struct {
+	ID    uint
+	Title string
+	Body  string
+	Versioned
+}
+
+func openDocuments(t *testing.T) *gorm.DB {
+	t.Helper()
+	return openTestDB(t, sqliteMemory, nil, &document{})
+}
+
+func TestUpdateVersionedDetectsConflicts(t *testing.T) {
+	conn := openDocuments(t)
+	ctx := context.Background()
+
+	original := &document{Title: "draft"}
+	if err := conn.Create(original).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if original.Version != 1 {
+		t.Fatalf("expected version 1 after create, got %d", original.Version)
+	}
+
+	first, second := *original, *original
+	first.Title = "first"
+	if err := UpdateVersioned(ctx, conn, &first); err != nil {
+		t.Fatalf("UpdateVersioned: %v", err)
+	}
+	if first.Version != 2 {
+		t.Fatalf("expected version 2, got %d", first.Version)
+	}
+
+	second.Title = "second"
+	err := UpdateVersioned(ctx, conn, &second)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrVersionConflict) || conflict.Version != 1 || conflict.Table != "documents" {
+		t.Fatalf("expected a conflict on version 1, got %v", err)
+	}
+	if !liberr.HasCode(err, liberr.CodeConflict) {
+		t.Fatalf("expected the conflict to carry CodeConflict")
+	}
+	if second.Version != 1 {
+		t.Fatalf("a failed update should keep the version, got %d", second.Version)
+	}
+
+	first.Title, first.Body = "ignored", "body"
+	if err := UpdateVersioned(ctx, conn, &first, "body"); err != nil {
+		t.Fatalf("UpdateVersioned with columns: %v", err)
+	}
+
+	var stored document
+	if err := conn.First(&stored, original.ID).Error; err != nil {
+		t.Fatalf("First: %v", err)
+	}
+	if stored.Title != "first" || stored.Body != "body" || stored.Version != 3 {
+		t.Fatalf("unexpected stored document %+v", stored)
+	}
+}
+
+func TestRepositoryUpdateUsesVersion(t *testing.T) {
+	conn := openDocuments(t)
+	repo, err := NewRepository[document](conn, repoSecret, 0)
+	if err != nil {
+		t.Fatalf("NewRepository: %v", err)
+	}
+	ctx := context.Background()
+
+	entity := &document{Title: "draft"}
+	if err := repo.Create(ctx, entity); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	stale := *entity
+
+	entity.Title = "final"
+	if err := repo.Update(ctx, entity); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := repo.Update(ctx, &stale); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+}
@@ -1,10 +1,20 @@
 package errors
 
-import "fmt"
+import (
+	stderrors "errors"
+	"fmt"
+)
 
 // Code identifies the error type.
 type Code string
 
+// Codes shared by the uker packages. httpx maps them to HTTP status codes.
+const (
+	CodeNotFound           Code = "not_found"
+	CodeConflict           Code = "conflict"
+	CodePreconditionFailed Code = "precondition_failed"
+)
+
 // Error represents a domain error with a code and an underlying error.
 type Error struct {
 	Code    Code
@@ -34,3 +44,18 @@ func (e Error) Error() string {
 func (e Error) Unwrap() error {
 	return e.Err
 }
+
+// CodeOf returns the code of the first Error in the chain of err.
+func CodeOf(err error) (Code, bool) {
+	var domainErr Error
+	if !stderrors.As(err, &domainErr) {
+		return "", false
+	}
+	return domainErr.Code, true
+}
+
+// HasCode reports whether the chain of err contains an Error with the given code.
+func HasCode(err error, code Code) bool {
+	found, ok := CodeOf(err)
+	return ok && found == code
+}
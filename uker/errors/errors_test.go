@@ -2,6 +2,7 @@ package errors
 
 import (
 	stderrors "errors"
+	"fmt"
 	"testing"
 )
 
@@ -26,3 +27,17 @@ func TestError(t *testing.T) {
 		t.Fatalf("Error() = %s", err.Error())
 	}
 }
+
+func TestCodeOf(t *testing.T) {
+	err := fmt.Errorf("saving: %w", New(CodeConflict, "stale version"))
+
+	if code, ok := CodeOf(err); !ok || code != CodeConflict {
+		t.Fatalf("CodeOf = %q, %v", code, ok)
+	}
+	if !HasCode(err, CodeConflict) || HasCode(err, CodeNotFound) {
+		t.Fatalf("unexpected HasCode result")
+	}
+	if _, ok := CodeOf(stderrors.New("plain")); ok {
+		t.Fatalf("expected no code for a plain error")
+	}
+}
@@ -0,0 +1,43 @@
+package httpx
+
+import (
+	"errors"
+	"net/http"
+
+	liberr "github.com/unknowns24/uker/uker/errors"
+)
+
+const codeInternalError = "internal_error"
+
+var statusByCode = map[liberr.Code]int{
+	liberr.CodeNotFound:           http.StatusNotFound,
+	liberr.CodeConflict:           http.StatusConflict,
+	liberr.CodePreconditionFailed: http.StatusPreconditionFailed,
+}
+
+// StatusFor returns the HTTP status matching the uker/errors code of err, or 500 when err
+// carries no known code.
+func StatusFor(err error) int {
+	code, ok := liberr.CodeOf(err)
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	if status, known := statusByCode[code]; known {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// WriteError writes err as an error Response with the status given by StatusFor. Only the
+// code and message of uker/errors values are exposed; other errors are reported as internal.
+func WriteError(w http.ResponseWriter, err error) {
+	status := ResponseStatus{Type: Error, Code: codeInternalError}
+
+	var domainErr liberr.Error
+	if errors.As(err, &domainErr) {
+		status.Code = string(domainErr.Code)
+		status.Description = domainErr.Message
+	}
+
+	ErrorOutput(w, StatusFor(err), Response{Status: status})
+}
@@ -0,0 +1,46 @@
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	liberr "github.com/unknowns24/uker/uker/errors"
+)
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+)
+
+// ETag formats a row version, such as db.Versioned, as a strong entity tag.
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// SetETag sets the ETag header of the response to the given version.
+func SetETag(w http.ResponseWriter, version int64) {
+	w.Header().Set(headerETag, ETag(version))
+}
+
+// IfMatch returns the version sent by the client in the If-Match header, so an update can be
+// applied only if the entity was not modified since it was read. ok is false when the header
+// is missing or "*". A malformed header or a list of several tags fails with a
+// liberr.CodePreconditionFailed error, written as 412 by WriteError.
+func IfMatch(r *http.Request) (version int64, ok bool, err error) {
+	header := strings.TrimSpace(r.Header.Get(headerIfMatch))
+	if header == "" || header == "*" {
+		return 0, false, nil
+	}
+
+	tag := strings.TrimPrefix(header, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false, liberr.New(liberr.CodePreconditionFailed, fmt.Sprintf("malformed If-Match header %q", header))
+	}
+	version, err = strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil {
+		return 0, false, liberr.Wrap(liberr.CodePreconditionFailed, fmt.Sprintf("If-Match header %q is not a version", header), err)
+	}
+	return version, true, nil
+}
@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	liberr "github.com/unknowns24/uker/uker/errors"
 	"github.com/unknowns24/uker/uker/httpx"
 )
 
@@ -376,3 +379,70 @@ func TestErrorOutput(t *testing.T) {
 		t.Fatalf("code = %s", status.Code)
 	}
 }
+
+func TestWriteErrorMapsCodes(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{fmt.Errorf("saving: %w", liberr.New(liberr.CodeConflict, "stale version")), http.StatusConflict, "conflict"},
+		{liberr.New(liberr.CodeNotFound, "missing"), http.StatusNotFound, "not_found"},
+		{liberr.New("custom", "unknown code"), http.StatusInternalServerError, "custom"},
+		{errors.New("db down"), http.StatusInternalServerError, "internal_error"},
+	}
+
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		httpx.WriteError(rec, tc.err)
+
+		if rec.Code != tc.status {
+			t.Fatalf("%v: status = %d", tc.err, rec.Code)
+		}
+		var response httpx.Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if response.Status.Type != httpx.Error || response.Status.Code != tc.code {
+			t.Fatalf("%v: status = %+v", tc.err, response.Status)
+		}
+		if strings.Contains(rec.Body.String(), "db down") {
+			t.Fatalf("internal errors should not be exposed: %s", rec.Body.String())
+		}
+	}
+}
+
+func TestIfMatchAndETag(t *testing.T) {
+	rec := httptest.NewRecorder()
+	httpx.SetETag(rec, 7)
+	if etag := rec.Header().Get("ETag"); etag != `"7"` {
+		t.Fatalf("ETag = %s", etag)
+	}
+
+	cases := map[string]struct {
+		version int64
+		ok      bool
+		failed  bool
+	}{
+		"":         {},
+		"*":        {},
+		`"7"`:      {version: 7, ok: true},
+		`W/"12"`:   {version: 12, ok: true},
+		`7`:        {failed: true},
+		`"abc"`:    {failed: true},
+		`"1", "2"`: {failed: true},
+	}
+
+	for header, want := range cases {
+		req := httptest.NewRequest(http.MethodPut, "/", nil)
+		req.Header.Set("If-Match", header)
+
+		version, ok, err := httpx.IfMatch(req)
+		if version != want.version || ok != want.ok || (err != nil) != want.failed {
+			t.Fatalf("IfMatch(%q) = %d, %v, %v", header, version, ok, err)
+		}
+		if err != nil && httpx.StatusFor(err) != http.StatusPreconditionFailed {
+			t.Fatalf("IfMatch(%q) error should map to 412", header)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package log
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	traceIDKey
+)
+
+// Field names used for the identifiers carried in the context.
+const (
+	FieldRequestID = "request_id"
+	FieldTraceID   = "trace_id"
+)
+
+// WithRequestID returns a copy of ctx carrying the request id of the current call.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request id stored with WithRequestID, or an empty string.
+func RequestID(ctx context.Context) string {
+	return contextString(ctx, requestIDKey)
+}
+
+// WithTraceID returns a copy of ctx carrying the distributed trace id of the current call.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey, id)
+}
+
+// TraceID returns the trace id stored with WithTraceID, or an empty string.
+func TraceID(ctx context.Context) string {
+	return contextString(ctx, traceIDKey)
+}
+
+// WithContext returns an entry tagged with the request and trace ids found in ctx.
+func (l *Logger) WithContext(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(l.Logger)
+	if ctx == nil {
+		return entry
+	}
+
+	entry = entry.WithContext(ctx)
+	if id := RequestID(ctx); id != "" {
+		entry = entry.WithField(FieldRequestID, id)
+	}
+	if id := TraceID(ctx); id != "" {
+		entry = entry.WithField(FieldTraceID, id)
+	}
+	return entry
+}
+
+func contextString(ctx context.Context, key contextKey) string {
+	if ctx == nil {
+		return ""
+	}
+	value, _ := ctx.Value(key).(string)
+	return value
+}
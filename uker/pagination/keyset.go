@@ -215,6 +215,44 @@ func validateBlockedFilters(filters map[string]string, blocked map[string]struct
 	return nil
 }
 
+// ValidateFields rejects sort expressions and filters referencing fields outside the given
+// allow lists. Unlike AllowedColumns it applies per call, so each repository can expose its
+// own columns. Matching ignores case and a single table alias prefix; an empty list allows
+// every field.
+func ValidateFields(params Params, sortFields, filterFields []string) error {
+	if len(sortFields) > 0 {
+		allowed, err := normaliseBlockedFields(sortFields)
+		if err != nil {
+			return err
+		}
+		for _, sort := range params.Sort {
+			if _, found := allowed[strings.ToLower(stripTableAlias(sort.Field))]; !found {
+				return ErrInvalidSort
+			}
+		}
+	}
+
+	if len(filterFields) > 0 {
+		allowed, err := normaliseBlockedFields(filterFields)
+		if err != nil {
+			return err
+		}
+		for key := range params.Filters {
+			fields, _, _, err := parseFilterKey(key)
+			if err != nil {
+				return err
+			}
+			for _, field := range fields {
+				if _, found := allowed[strings.ToLower(stripTableAlias(field))]; !found {
+					return ErrInvalidFilter
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 func filterField(key string) (string, error) {
 	idx := strings.LastIndex(key, "_")
 	if idx <= 0 || idx == len(key)-1 {
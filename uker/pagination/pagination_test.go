@@ -471,6 +471,26 @@ func TestParseWithSecurityBlockedFiltersNilMatchesExistingBehaviour(t *testing.T
 	}
 }
 
+func TestValidateFieldsHonoursAllowLists(t *testing.T) {
+	params := pagination.Params{
+		Sort:    []pagination.SortExpression{{Field: "orders.created_at", Direction: pagination.DirectionDesc}},
+		Filters: map[string]string{"status,Kind_eq": "open"},
+	}
+
+	if err := pagination.ValidateFields(params, []string{"created_at"}, []string{"status", "kind"}); err != nil {
+		t.Fatalf("unexpected error for allowed fields: %v", err)
+	}
+	if err := pagination.ValidateFields(params, nil, nil); err != nil {
+		t.Fatalf("empty allow lists should accept every field, got %v", err)
+	}
+	if err := pagination.ValidateFields(params, []string{"id"}, nil); err != pagination.ErrInvalidSort {
+		t.Fatalf("expected invalid sort, got %v", err)
+	}
+	if err := pagination.ValidateFields(params, nil, []string{"status"}); err != pagination.ErrInvalidFilter {
+		t.Fatalf("expected invalid filter, got %v", err)
+	}
+}
+
 func TestParseWithSecurityExpiredCursor(t *testing.T) {
 	cursorPayload := pagination.CursorPayload{
 		Version:   1,
@@ -0,0 +1,156 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// OneOf validates that the provided string matches one of the options.
+func OneOf(value string, options ...string) error {
+	for _, option := range options {
+		if value == option {
+			return nil
+		}
+	}
+	return fmt.Errorf("value must be one of [%s]", strings.Join(options, " "))
+}
+
+// URL validates that the provided string is an absolute URL with scheme and host.
+func URL(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return errors.New("value must be an absolute URL")
+	}
+	return nil
+}
+
+// Port validates that the provided string is a TCP/UDP port between 1 and 65535.
+func Port(value string) error {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return errors.New("value must be a port between 1 and 65535")
+	}
+	return nil
+}
+
+// HostPort validates that the provided string has the host:port form with a valid port.
+func HostPort(value string) error {
+	host, port, err := net.SplitHostPort(value)
+	if err != nil {
+		return errors.New("value must use the host:port format")
+	}
+	if host == "" {
+		return errors.New("value must include a host")
+	}
+	return Port(port)
+}
+
+// Rules validates value against a comma separated rule list as used in `uker` tags:
+//
+//	min=N, max=N  numeric bounds, or length bounds for strings, slices and maps
+//	              (durations accept values such as min=1s)
+//	oneof=a b c   the value must match one of the space separated options
+//	url           absolute URL with scheme and host
+//	hostport      host:port with a valid port
+//	port          port between 1 and 65535
+//
+// The `required` rule is ignored here since presence is checked by RequiredFields and by the
+// config loader.
+func Rules(value any, rules string) error {
+	rv := reflect.ValueOf(value)
+	for rv.IsValid() && rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if err := applyRule(rv, name, arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyRule(value reflect.Value, name, arg string) error {
+	switch name {
+	case "", tagRequiredValue:
+		return nil
+	case "min", "max":
+		return checkBound(value, name, arg)
+	case "oneof":
+		return OneOf(fmt.Sprint(value.Interface()), strings.Fields(arg)...)
+	case "url":
+		if value.Type() == reflect.TypeOf(url.URL{}) {
+			u := value.Interface().(url.URL)
+			return URL(u.String())
+		}
+		return URL(fmt.Sprint(value.Interface()))
+	case "hostport":
+		return HostPort(fmt.Sprint(value.Interface()))
+	case "port":
+		return Port(fmt.Sprint(value.Interface()))
+	default:
+		return fmt.Errorf("unknown validation rule %q", name)
+	}
+}
+
+func checkBound(value reflect.Value, name, arg string) error {
+	var (
+		actual, bound float64
+		subject       = "value"
+	)
+
+	switch {
+	case value.Type() == durationType:
+		parsed, err := time.ParseDuration(arg)
+		if err != nil {
+			return fmt.Errorf("invalid %s rule: %w", name, err)
+		}
+		actual, bound = float64(value.Int()), float64(parsed)
+	case value.Kind() == reflect.String || value.Kind() == reflect.Slice || value.Kind() == reflect.Map || value.Kind() == reflect.Array:
+		parsed, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid %s rule: %w", name, err)
+		}
+		actual, bound, subject = float64(value.Len()), float64(parsed), "length"
+	default:
+		parsed, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s rule: %w", name, err)
+		}
+		bound = parsed
+
+		switch value.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			actual = float64(value.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			actual = float64(value.Uint())
+		case reflect.Float32, reflect.Float64:
+			actual = value.Float()
+		default:
+			return fmt.Errorf("%s rule not supported for %s", name, value.Kind())
+		}
+	}
+
+	if name == "min" && actual < bound {
+		return fmt.Errorf("%s must be at least %s", subject, arg)
+	}
+	if name == "max" && actual > bound {
+		return fmt.Errorf("%s must be at most %s", subject, arg)
+	}
+	return nil
+}
@@ -7,10 +7,10 @@ import (
 	"strings"
 )
 
-const (
-	tagName          = "uker"
-	tagRequiredValue = "required"
-)
+// TagName is the struct tag read by the validation helpers, e.g. `uker:"required,min=1"`.
+const TagName = "uker"
+
+const tagRequiredValue = "required"
 
 // NotEmpty validates that the provided string is not empty.
 func NotEmpty(value string) error {
@@ -115,7 +115,7 @@ func requiredFieldsForValue(value reflect.Value, payload any, path string) error
 func requiredFieldsForStruct(elem reflect.Value, body map[string]any, path string) error {
 	for i := 0; i < elem.NumField(); i++ {
 		field := elem.Type().Field(i)
-		if !strings.Contains(field.Tag.Get(tagName), tagRequiredValue) {
+		if !strings.Contains(field.Tag.Get(TagName), tagRequiredValue) {
 			continue
 		}
 
@@ -3,6 +3,7 @@ package validate
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNotEmpty(t *testing.T) {
@@ -106,3 +107,46 @@ func TestRequiredFieldsFromPayloadSliceMissingRequiredIncludesIndex(t *testing.T
 		t.Fatalf("expected error to include index, got %q", err.Error())
 	}
 }
+
+func TestRules(t *testing.T) {
+	cases := []struct {
+		name  string
+		value any
+		rules string
+		valid bool
+	}{
+		{"min int", 5, "min=1,max=10", true},
+		{"below min", 0, "min=1", false},
+		{"above max", 11, "max=10", false},
+		{"string length", "abc", "min=2,max=3", true},
+		{"string too long", "abcd", "max=3", false},
+		{"duration", 2 * time.Second, "min=1s,max=1m", true},
+		{"negative duration", -time.Second, "min=0s", false},
+		{"oneof", "info", "oneof=debug info", true},
+		{"not oneof", "trace", "oneof=debug info", false},
+		{"url", "https://example.com", "url", true},
+		{"bad url", "example.com", "url", false},
+		{"hostport", "db:3306", "hostport", true},
+		{"bad hostport", "db", "hostport", false},
+		{"port", 8080, "port", true},
+		{"bad port", "abc", "port", false},
+		{"required ignored", "", "required", true},
+		{"nil pointer", (*int)(nil), "min=1", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Rules(tc.value, tc.rules)
+			if tc.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+
+	if err := Rules(1, "unknown"); err == nil {
+		t.Fatalf("expected error for unknown rule")
+	}
+}